@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/safeie/spider/common/daemon"
+	"github.com/safeie/spider/component/manager"
+	"github.com/safeie/spider/component/schedule"
+	"github.com/safeie/spider/component/spec"
+	"github.com/safeie/spider/component/task"
+)
+
+// daemonCmd 在一个常驻进程中按任务描述的 schedule 定时执行多个任务
+func daemonCmd(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	output := fs.String("o", "", "output file for saved rows, default stdout")
+	detach := fs.Bool("d", false, "run in background")
+	concurrency := fs.Int("c", 0, "fetch routines shared by all tasks, 0 for no limit")
+	httpAddr := fs.String("http", "", "address of the control api, like :8080")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("spec files are required")
+	}
+
+	specs := make([]*spec.Spec, 0, fs.NArg())
+	for _, file := range fs.Args() {
+		s, err := spec.Load(file)
+		if err != nil {
+			return err
+		}
+		if s.Schedule == "" {
+			return fmt.Errorf("%s: schedule is empty", file)
+		}
+		specs = append(specs, s)
+	}
+
+	if *detach {
+		if _, err := daemon.Daemon(1, 0); err != nil {
+			return err
+		}
+	}
+
+	w, err := openOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	sched := schedule.New()
+	save := rowWriter(w)
+	var m *manager.Manager
+	if *concurrency > 0 {
+		m = manager.New(*concurrency)
+	}
+	var tasks []*task.Task
+	for _, s := range specs {
+		t, err := s.Build(save)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, t)
+		if m != nil {
+			if err = m.Add(t); err != nil {
+				return err
+			}
+		}
+		if err = sched.Add(t, s.Schedule); err != nil {
+			return err
+		}
+	}
+	serveControl(*httpAddr, tasks...)
+	sched.Start()
+	for _, st := range sched.Statuses() {
+		log.Printf("task %s scheduled %q, next run at %s", st.ID, st.Spec, st.Next.Format("2006-01-02 15:04:05"))
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-ch
+	log.Printf("signal received %s, stopping...", sig)
+	sched.Stop()
+	for _, st := range sched.Statuses() {
+		log.Printf("task %s runs %d, skipped %d, last error %q", st.ID, st.Runs, st.Skipped, st.LastError)
+	}
+	return nil
+}
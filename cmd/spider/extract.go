@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/safeie/spider/component/spec"
+	"github.com/safeie/spider/component/task"
+)
+
+// extractCmd 对单个页面执行规则的字段提取，输出数据行JSON
+func extractCmd(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	ruleName := fs.String("rule", "", "rule name, default the first rule matches the url")
+	pageURL := fs.String("url", "", "page url when extract from a local file")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("spec and file-or-url are required")
+	}
+
+	s, err := spec.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	t, err := s.Build(nil)
+	if err != nil {
+		return err
+	}
+
+	src := fs.Arg(1)
+	if *pageURL == "" {
+		*pageURL = src
+	}
+	u, err := loadPage(t, src, *pageURL)
+	if err != nil {
+		return err
+	}
+
+	r := findRule(t, *ruleName, u.URL)
+	if r == nil {
+		return fmt.Errorf("no rule matches %s", u.URL)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r.Extract(u))
+}
+
+// findRule 按名称或URL查找规则，本地文件没有匹配的规则时使用第一个规则
+func findRule(t *task.Task, name, u string) *task.Rule {
+	rules := t.Rules()
+	for _, r := range rules {
+		if name != "" {
+			if r.Name() == name {
+				return r
+			}
+			continue
+		}
+		if r.Match(u) {
+			return r
+		}
+	}
+	if name == "" && len(rules) > 0 {
+		return rules[0]
+	}
+	return nil
+}
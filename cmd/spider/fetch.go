@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/safeie/spider/common/util"
+	"github.com/safeie/spider/component/spec"
+	"github.com/safeie/spider/component/task"
+	"github.com/safeie/spider/component/url"
+)
+
+// fetchCmd 使用任务的抓取参数获取一个页面，输出状态码，头信息和内容
+func fetchCmd(args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	specFile := fs.String("spec", "", "spec file to take fetch options from")
+	body := fs.Bool("body", true, "print response body")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("url is required")
+	}
+
+	t, err := newTask(*specFile)
+	if err != nil {
+		return err
+	}
+	u, _, err := t.FetchURL(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d %s\n", u.Code, u.URL)
+	keys := make([]string, 0, len(u.Header))
+	for k := range u.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s: %s\n", k, strings.Join(u.Header[k], ", "))
+	}
+	if *body {
+		fmt.Println()
+		os.Stdout.Write(u.Body)
+		fmt.Println()
+	}
+	return nil
+}
+
+// newTask 根据任务描述创建任务，没有描述时使用默认的抓取参数
+func newTask(specFile string) (*task.Task, error) {
+	if specFile == "" {
+		return task.New("fetch", "fetch", "", ""), nil
+	}
+	s, err := spec.Load(specFile)
+	if err != nil {
+		return nil, err
+	}
+	return s.Build(nil)
+}
+
+// loadPage 获取页面内容，参数为URL时抓取页面，否则读取本地文件
+func loadPage(t *task.Task, src, pageURL string) (*url.URI, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		u, _, err := t.FetchURL(src)
+		return u, err
+	}
+	body, err := util.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	u := url.NewURI(pageURL)
+	u.Body = body
+	u.Fetched = true
+	return u, nil
+}
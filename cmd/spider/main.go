@@ -0,0 +1,58 @@
+// Command spider 是爬虫组件的命令行工具
+//
+//	spider run [-o output] [-state dir] [-http addr] <spec>
+//	                                                执行一个声明式任务
+//	spider fetch [-spec spec] <url>                 使用任务的抓取参数获取一个页面
+//	spider extract [-rule name] [-url url] <spec> <file-or-url>
+//	                                                对单个页面执行字段提取，输出JSON
+//	spider resume [-o output] <state-dir>           继续执行保存的队列
+//	spider daemon [-d] [-c n] [-http addr] [-o output] <spec>...
+//	                                                按任务描述的 schedule 定时执行多个任务
+//	spider test [-type type] [-suggest text] <file-or-url> [rule]
+//	                                                交互式测试字段规则
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []*command{
+	{"run", "run [-o output] [-state dir] [-http addr] <spec>", runCmd},
+	{"fetch", "fetch [-spec spec] [-body=false] <url>", fetchCmd},
+	{"extract", "extract [-rule name] [-url url] <spec> <file-or-url>", extractCmd},
+	{"resume", "resume [-o output] <state-dir>", resumeCmd},
+	{"daemon", "daemon [-d] [-c n] [-http addr] [-o output] <spec>...", daemonCmd},
+	{"test", "test [-type type] [-suggest text] <file-or-url> [rule]", testCmd},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "spider %s: %v\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  spider %s\n", c.usage)
+	}
+}
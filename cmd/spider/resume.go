@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/safeie/spider/common/util"
+	"github.com/safeie/spider/component/spec"
+)
+
+const (
+	stateSpecFile  = "spec.json"
+	stateQueueFile = "queue.txt"
+)
+
+// resumeCmd 继续执行状态目录中保存的队列
+func resumeCmd(args []string) error {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	output := fs.String("o", "", "output file for saved rows, default stdout")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("state dir is required")
+	}
+	stateDir := fs.Arg(0)
+
+	s, err := spec.Load(filepath.Join(stateDir, stateSpecFile))
+	if err != nil {
+		return err
+	}
+	queue, err := loadStateQueue(stateDir)
+	if err != nil {
+		return err
+	}
+	if len(queue) == 0 {
+		return fmt.Errorf("no saved queue in %s", stateDir)
+	}
+	return runSpec(s, *output, stateDir, "", queue)
+}
+
+// saveStateSpec 保存任务描述到状态目录
+func saveStateSpec(dir string, s *spec.Spec) error {
+	if err := util.MkdirAll(dir); err != nil {
+		return err
+	}
+	body, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = util.WriteFile(filepath.Join(dir, stateSpecFile), body)
+	return err
+}
+
+// saveStateQueue 保存未完成的队列到状态目录，每行一个URL
+func saveStateQueue(dir string, queue []string) error {
+	var buf bytes.Buffer
+	for _, u := range queue {
+		buf.WriteString(u)
+		buf.WriteByte('\n')
+	}
+	_, err := util.WriteFile(filepath.Join(dir, stateQueueFile), buf.Bytes())
+	return err
+}
+
+// loadStateQueue 读取状态目录中保存的队列
+func loadStateQueue(dir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, stateQueueFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var queue []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if u := strings.TrimSpace(s.Text()); u != "" {
+			queue = append(queue, u)
+		}
+	}
+	return queue, s.Err()
+}
+
+// removeStateQueue 任务正常结束，删除保存的队列
+func removeStateQueue(dir string) {
+	os.Remove(filepath.Join(dir, stateQueueFile))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/safeie/spider/common/metrics"
+	"github.com/safeie/spider/component/control"
+	"github.com/safeie/spider/component/spec"
+	"github.com/safeie/spider/component/task"
+)
+
+// runCmd 执行一个声明式任务
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	output := fs.String("o", "", "output file for saved rows, default stdout")
+	stateDir := fs.String("state", "", "state dir to save the queue when stopped")
+	httpAddr := fs.String("http", "", "address of the control api, like :8080")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("spec file is required")
+	}
+
+	s, err := spec.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *stateDir != "" {
+		if err = saveStateSpec(*stateDir, s); err != nil {
+			return err
+		}
+	}
+	return runSpec(s, *output, *stateDir, *httpAddr, nil)
+}
+
+// runSpec 构建并执行任务，queue 不为空时作为入口URL，httpAddr 不为空时提供控制接口
+func runSpec(s *spec.Spec, output, stateDir, httpAddr string, queue []string) error {
+	w, err := openOutput(output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	t, err := s.Build(rowWriter(w))
+	if err != nil {
+		return err
+	}
+	if queue != nil {
+		t.SetURLinitFunc(func() []string {
+			return queue
+		})
+	}
+
+	saved := false
+	if stateDir != "" {
+		t.SetBeforeQuitFunc(func(taskID string, queue []string) {
+			if err := saveStateQueue(stateDir, queue); err != nil {
+				log.Printf("save queue error: %v", err)
+				return
+			}
+			saved = true
+			log.Printf("task %s stopped, %d urls saved to %s", taskID, len(queue), stateDir)
+		})
+	}
+
+	stopOnSignal(t)
+	serveControl(httpAddr, t)
+	err = t.Run()
+	if stateDir != "" && !saved {
+		removeStateQueue(stateDir)
+	}
+	return err
+}
+
+// stopOnSignal 收到退出信号时停止任务并保存队列
+func stopOnSignal(t *task.Task) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-ch
+		log.Printf("signal received %s, stopping...", sig)
+		t.Stop()
+	}()
+}
+
+// serveControl 在 addr 上提供任务的控制接口和 /metrics 指标，addr 为空时不处理
+func serveControl(addr string, tasks ...*task.Task) {
+	if addr == "" {
+		return
+	}
+	reg := metrics.NewRegistry()
+	for _, t := range tasks {
+		t.SetMetrics(reg)
+	}
+	s := control.New().Add(tasks...).SetMetrics(reg)
+	go func() {
+		if err := s.ListenAndServe(addr); err != nil {
+			log.Printf("control api error: %v", err)
+		}
+	}()
+}
+
+// openOutput 打开数据输出
+func openOutput(output string) (io.WriteCloser, error) {
+	if output == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// rowWriter 将每条数据按行输出为JSON
+func rowWriter(w io.Writer) task.SaveFunc {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(taskID, pk string, val map[string]interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(map[string]interface{}{
+			"task": taskID,
+			"pk":   pk,
+			"data": val,
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/safeie/spider/component/parser"
+	"github.com/safeie/spider/component/spec"
+	"github.com/safeie/spider/component/url"
+)
+
+// testCmd 交互式测试字段规则，加载一个页面，执行规则并输出全部匹配及其位置
+//
+// 交互模式下，每行输入一条规则，以冒号开头的是命令：
+//
+//	:type <selector|substring|regexp|jsonpath|xpath|re2|readability|jsdata|feed|table>  切换匹配类型
+//	:suggest <text>                                                                     为包含文本的元素推荐选择器
+//	:quit                                                                               退出
+func testCmd(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	typ := fs.String("type", "selector", "match type: selector, substring, regexp, jsonpath, xpath, re2, readability, jsdata, feed, table")
+	suggest := fs.String("suggest", "", "suggest selectors for the element contains the text")
+	specFile := fs.String("spec", "", "spec file to take fetch options from")
+	width := fs.Int("n", 120, "max width of printed values, 0 for no limit")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		return fmt.Errorf("file-or-url is required")
+	}
+
+	t, err := newTask(*specFile)
+	if err != nil {
+		return err
+	}
+	u, err := loadPage(t, fs.Arg(0), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// PDF 文档转为文本后测试
+	if parser.IsPDF(u.Body) {
+		u.PageType = url.PageTypePDF
+		if err = u.DecodeBody(); err != nil {
+			return err
+		}
+	}
+
+	tester := &ruleTester{body: u.Body, width: *width}
+	if err = tester.setType(*typ); err != nil {
+		return err
+	}
+	if *suggest != "" {
+		return tester.suggest(*suggest)
+	}
+	if fs.NArg() > 1 {
+		return tester.eval(strings.Join(fs.Args()[1:], " "))
+	}
+	return tester.loop()
+}
+
+type ruleTester struct {
+	body      []byte
+	width     int
+	typeName  string
+	matchType int
+}
+
+func (t *ruleTester) setType(name string) error {
+	v, ok := spec.MatchType(name)
+	if !ok {
+		return fmt.Errorf("unknown match type %q", name)
+	}
+	t.typeName = name
+	t.matchType = v
+	return nil
+}
+
+// loop 交互模式，逐行读取规则并执行
+func (t *ruleTester) loop() error {
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Printf("%s> ", t.typeName)
+		if !in.Scan() {
+			fmt.Println()
+			return in.Err()
+		}
+		line := strings.TrimSpace(in.Text())
+		if line == "" {
+			continue
+		}
+		var err error
+		switch {
+		case line == ":quit" || line == ":q":
+			return nil
+		case strings.HasPrefix(line, ":type "):
+			err = t.setType(strings.TrimSpace(line[6:]))
+		case strings.HasPrefix(line, ":suggest "):
+			err = t.suggest(strings.TrimSpace(line[9:]))
+		case strings.HasPrefix(line, ":"):
+			err = fmt.Errorf("unknown command %q", line)
+		default:
+			err = t.eval(line)
+		}
+		if err != nil {
+			fmt.Println("error:", err)
+		}
+	}
+}
+
+// eval 执行规则，输出全部匹配
+func (t *ruleTester) eval(rule string) error {
+	ms, err := url.Evaluate(t.body, t.matchType, rule)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d matches\n", len(ms))
+	for i, m := range ms {
+		printMatch(i, m, t.width)
+	}
+	return nil
+}
+
+// suggest 为包含文本的元素推荐选择器
+func (t *ruleTester) suggest(text string) error {
+	dom, err := parser.NewHTMLDom(t.body)
+	if err != nil {
+		return err
+	}
+	sels := dom.SuggestSelector(text)
+	if len(sels) == 0 {
+		return fmt.Errorf("no element contains %q", text)
+	}
+	for _, sel := range sels {
+		fmt.Println(sel)
+	}
+	return nil
+}
+
+func printMatch(i int, m parser.Match, width int) {
+	pos := "-"
+	if m.Offset >= 0 {
+		pos = fmt.Sprintf("offset %d, line %d:%d", m.Offset, m.Line, m.Column)
+	}
+	fmt.Printf("[%d] %s", i, pos)
+	if m.Path != "" {
+		fmt.Printf(", %s", m.Path)
+	}
+	fmt.Println()
+	v := strings.Join(strings.Fields(m.Value), " ")
+	if r := []rune(v); width > 0 && len(r) > width {
+		v = string(r[:width]) + "..."
+	}
+	fmt.Printf("    %s\n", v)
+}
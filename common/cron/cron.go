@@ -0,0 +1,245 @@
+// Package cron 解析 cron 表达式，计算下一次执行的时间
+//
+// 表达式有5个字段：分 时 日 月 周，或者6个字段，第一个字段为秒：
+//
+//	字段    取值              特殊字符
+//	秒      0-59              * , - /
+//	分      0-59              * , - /
+//	时      0-23              * , - /
+//	日      1-31              * , - / ?
+//	月      1-12 或 JAN-DEC   * , - /
+//	周      0-7 或 SUN-SAT    * , - / ?   (0 和 7 都是周日)
+//
+// 日和周都不是 * 时，满足其中一个即可。也支持预定义的表达式：
+// @yearly(@annually) @monthly @weekly @daily(@midnight) @hourly 和 @every <时长>，如 @every 1h30m
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 执行计划
+type Schedule interface {
+	// Next 返回给定时间之后的下一次执行时间，没有时返回零值
+	Next(t time.Time) time.Time
+}
+
+// field 字段的取值范围和名称
+type field struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	seconds = field{"second", 0, 59, nil}
+	minutes = field{"minute", 0, 59, nil}
+	hours   = field{"hour", 0, 23, nil}
+	days    = field{"day of month", 1, 31, nil}
+	months  = field{"month", 1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	weekdays = field{"day of week", 0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors 预定义的表达式
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// SpecSchedule cron 表达式的执行计划，每个字段以位表示允许的值
+type SpecSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool // 日和周是否为 * 或 ?
+}
+
+// EverySchedule 固定间隔的执行计划
+type EverySchedule struct {
+	Interval time.Duration
+}
+
+// Every 返回固定间隔的执行计划，间隔不足1秒按1秒计算
+func Every(d time.Duration) EverySchedule {
+	if d < time.Second {
+		d = time.Second
+	}
+	return EverySchedule{Interval: d - d%time.Second}
+}
+
+// Next 返回给定时间加一个间隔，按秒取整
+func (s EverySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.Interval - time.Duration(t.Nanosecond()))
+}
+
+// Parse 解析 cron 表达式或预定义的表达式
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("cron: %v", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("cron: @every needs a positive duration")
+		}
+		return Every(d), nil
+	}
+	if strings.HasPrefix(spec, "@") {
+		v, ok := descriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("cron: unknown descriptor %q", spec)
+		}
+		spec = v
+	}
+
+	items := strings.Fields(spec)
+	switch len(items) {
+	case 5:
+		items = append([]string{"0"}, items...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron: expected 5 or 6 fields, found %d in %q", len(items), spec)
+	}
+
+	s := new(SpecSchedule)
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		f    field
+	}{
+		{&s.second, seconds},
+		{&s.minute, minutes},
+		{&s.hour, hours},
+		{&s.dom, days},
+		{&s.month, months},
+		{&s.dow, weekdays},
+	} {
+		if *f.bits, err = parseField(items[i], f.f); err != nil {
+			return nil, err
+		}
+	}
+	// 周日可以写为 7
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = items[3] == "*" || items[3] == "?"
+	s.dowStar = items[5] == "*" || items[5] == "?"
+	return s, nil
+}
+
+// parseField 解析一个字段，逗号分隔的每一项为 *, ?, n, a-b，可以跟 /step
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		step := uint(1)
+		if p := strings.Index(item, "/"); p >= 0 {
+			n, err := strconv.ParseUint(item[p+1:], 10, 8)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("cron: bad step in %s %q", f.name, item)
+			}
+			step = uint(n)
+			item = item[:p]
+		}
+		var lo, hi uint
+		switch {
+		case item == "*" || item == "?":
+			if item == "?" && f.name != days.name && f.name != weekdays.name {
+				return 0, fmt.Errorf("cron: ? is not allowed in %s", f.name)
+			}
+			lo, hi = f.min, f.max
+			if f.name == weekdays.name {
+				hi = 6
+			}
+		case strings.Contains(item, "-"):
+			p := strings.Index(item, "-")
+			var err error
+			if lo, err = parseValue(item[:p], f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(item[p+1:], f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("cron: bad range in %s %q", f.name, item)
+			}
+		default:
+			v, err := parseValue(item, f)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			if step > 1 {
+				hi = f.max // a/n 表示从 a 开始每 n 个
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// parseValue 解析一个数字或名称，检查范围
+func parseValue(s string, f field) (uint, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(n) < f.min || uint(n) > f.max {
+		return 0, fmt.Errorf("cron: bad value in %s %q", f.name, s)
+	}
+	return uint(n), nil
+}
+
+// has 判断值是否允许
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// dayMatches 判断日期是否满足日和周，两者都有限制时满足其中一个即可
+func (s *SpecSchedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next 返回给定时间之后的下一次执行时间，使用给定时间的时区，5年内没有时返回零值
+func (s *SpecSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		y, mo, d := t.Date()
+		h, mi, sec := t.Clock()
+		switch {
+		case !has(s.month, int(mo)):
+			t = time.Date(y, mo+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(y, mo, d+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, h):
+			t = time.Date(y, mo, d, h+1, 0, 0, 0, loc)
+		case !has(s.minute, mi):
+			t = time.Date(y, mo, d, h, mi+1, 0, 0, loc)
+		case !has(s.second, sec):
+			t = time.Date(y, mo, d, h, mi, sec+1, 0, loc)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
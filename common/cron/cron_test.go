@@ -0,0 +1,74 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParse(t *testing.T) {
+	Convey("测试解析 cron 表达式", t, func() {
+		for _, spec := range []string{
+			"* * * * *",
+			"*/5 * * * *",
+			"0 9-18/2 * * MON-FRI",
+			"30 0 1,15 * ?",
+			"0 0 0 1 JAN *",
+			"0 0 * * 7",
+			"@hourly",
+			"@every 90s",
+		} {
+			_, err := Parse(spec)
+			So(err, ShouldBeNil)
+		}
+		for _, spec := range []string{
+			"",
+			"* * * *",
+			"60 * * * *",
+			"* 24 * * *",
+			"* * 0 * *",
+			"* * * 13 *",
+			"*/0 * * * *",
+			"5-1 * * * *",
+			"? * * * *",
+			"@often",
+			"@every x",
+		} {
+			_, err := Parse(spec)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestNext(t *testing.T) {
+	Convey("测试计算下一次执行时间", t, func() {
+		base := time.Date(2024, 1, 31, 10, 17, 30, 500, time.UTC) // 周三
+		cases := []struct {
+			spec string
+			next time.Time
+		}{
+			{"* * * * *", time.Date(2024, 1, 31, 10, 18, 0, 0, time.UTC)},
+			{"*/15 * * * *", time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)},
+			{"*/10 * * * * *", time.Date(2024, 1, 31, 10, 17, 40, 0, time.UTC)},
+			{"0 9 * * *", time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+			{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+			{"0 0 31 * *", time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+			{"0 8 * * SUN", time.Date(2024, 2, 4, 8, 0, 0, 0, time.UTC)},
+			{"0 8 * * 7", time.Date(2024, 2, 4, 8, 0, 0, 0, time.UTC)},
+			{"0 8 15 * MON", time.Date(2024, 2, 5, 8, 0, 0, 0, time.UTC)}, // 日和周满足其一
+			{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+			{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+			{"@every 1h", time.Date(2024, 1, 31, 11, 17, 30, 0, time.UTC)},
+		}
+		for _, c := range cases {
+			s, err := Parse(c.spec)
+			So(err, ShouldBeNil)
+			So(s.Next(base), ShouldEqual, c.next)
+		}
+
+		// 不存在的日期
+		s, _ := Parse("0 0 30 2 *")
+		So(s.Next(base).IsZero(), ShouldBeTrue)
+	})
+}
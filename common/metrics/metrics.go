@@ -0,0 +1,333 @@
+// Package metrics 提供计数器、仪表和直方图，按 Prometheus 文本格式输出
+//
+// 指标注册在调用方创建的 Registry 中，没有全局的注册表，多个任务可以共用一个 Registry，
+// 同名的指标只注册一次，通过标签区分：
+//
+//	reg := metrics.NewRegistry()
+//	fetches := reg.Counter("spider_fetches_total", "抓取的次数", "task", "status")
+//	fetches.Inc("news", "200")
+//	http.Handle("/metrics", reg)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets 默认的直方图分段，单位 秒
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// 指标类型
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Registry 指标注册表，实现 http.Handler 输出全部指标
+type Registry struct {
+	metrics map[string]*metric // 指标，键为指标名称
+	mu      sync.Mutex
+}
+
+// NewRegistry 创建一个注册表
+func NewRegistry() *Registry {
+	r := new(Registry)
+	r.metrics = make(map[string]*metric)
+	return r
+}
+
+// Counter 注册一个计数器，同名的计数器已经注册时返回已有的计数器
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, typeCounter, nil, labels)}
+}
+
+// Gauge 注册一个仪表，同名的仪表已经注册时返回已有的仪表
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, typeGauge, nil, labels)}
+}
+
+// Histogram 注册一个直方图，buckets 为空时使用 DefBuckets，同名的直方图已经注册时返回已有的直方图
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{r.register(name, help, typeHistogram, buckets, labels)}
+}
+
+// register 注册一个指标，名称相同但类型或标签不同时 panic
+func (r *Registry) register(name, help, typ string, buckets []float64, labels []string) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if m.typ != typ || strings.Join(m.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("metrics: %s already registered as %s%v", name, m.typ, m.labels))
+		}
+		return m
+	}
+	m := &metric{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.metrics[name] = m
+	return m
+}
+
+// WriteTo 按 Prometheus 文本格式输出全部指标，按名称排序
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	list := make([]*metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		list = append(list, m)
+	}
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range list {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP 输出全部指标
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// Counter 计数器，只增不减
+type Counter struct {
+	m *metric
+}
+
+// Inc 加1，values 为标签的值，数量与注册时的标签一致
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add 增加 v，v 小于0时忽略
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	s := c.m.get(values)
+	c.m.mu.Lock()
+	s.value += v
+	c.m.mu.Unlock()
+}
+
+// Gauge 仪表，可增可减，也可以在输出时通过函数取值
+type Gauge struct {
+	m *metric
+}
+
+// Set 设置为 v
+func (g *Gauge) Set(v float64, values ...string) {
+	s := g.m.get(values)
+	g.m.mu.Lock()
+	s.value = v
+	s.fn = nil
+	g.m.mu.Unlock()
+}
+
+// Add 增加 v，v 可以为负数
+func (g *Gauge) Add(v float64, values ...string) {
+	s := g.m.get(values)
+	g.m.mu.Lock()
+	s.value += v
+	g.m.mu.Unlock()
+}
+
+// SetFunc 设置取值函数，每次输出时调用，如队列长度
+func (g *Gauge) SetFunc(fn func() float64, values ...string) {
+	s := g.m.get(values)
+	g.m.mu.Lock()
+	s.fn = fn
+	g.m.mu.Unlock()
+}
+
+// Delete 删除一组标签的值
+func (g *Gauge) Delete(values ...string) {
+	g.m.delete(values)
+}
+
+// Histogram 直方图，统计观测值的分布
+type Histogram struct {
+	m *metric
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := h.m.get(values)
+	h.m.mu.Lock()
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.m.buckets))
+	}
+	for i, b := range h.m.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+	h.m.mu.Unlock()
+}
+
+// metric 一个指标，包含多组标签的值
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string           // 标签名称
+	buckets []float64          // 直方图的分段上限
+	series  map[string]*series // 每组标签的值，键为标签值的组合
+	mu      sync.Mutex
+}
+
+// series 一组标签的值
+type series struct {
+	values []string       // 标签值
+	value  float64        // 计数器、仪表的值，直方图的总和
+	fn     func() float64 // 仪表的取值函数
+	counts []uint64       // 直方图每个分段的累计数量
+	count  uint64         // 直方图的观测次数
+}
+
+// get 返回一组标签的值，不存在时创建，标签值数量不一致时 panic
+func (m *metric) get(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		m.series[key] = s
+	}
+	return s
+}
+
+// delete 删除一组标签的值
+func (m *metric) delete(values []string) {
+	m.mu.Lock()
+	delete(m.series, strings.Join(values, "\xff"))
+	m.mu.Unlock()
+}
+
+// write 按文本格式输出指标，没有值时不输出
+func (m *metric) write(w *bufio.Writer) {
+	m.mu.Lock()
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]series, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, *m.series[k])
+		s := &list[len(list)-1]
+		s.counts = append([]uint64(nil), s.counts...)
+	}
+	m.mu.Unlock()
+	if len(list) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+	for _, s := range list {
+		if m.typ != typeHistogram {
+			v := s.value
+			if s.fn != nil {
+				v = s.fn()
+			}
+			fmt.Fprintf(w, "%s%s %s\n", m.name, m.labelText(s.values, "", ""), formatFloat(v))
+			continue
+		}
+		for i, b := range m.buckets {
+			var n uint64
+			if s.counts != nil {
+				n = s.counts[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.labelText(s.values, "le", formatFloat(b)), n)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.labelText(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, m.labelText(s.values, "", ""), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, m.labelText(s.values, "", ""), s.count)
+	}
+}
+
+// labelText 返回标签文本，如 {task="news",status="200"}，extra 不为空时附加在最后
+func (m *metric) labelText(values []string, extra, extraValue string) string {
+	if len(values) == 0 && extra == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, m.labels[i]+`="`+escapeLabel(v)+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra+`="`+extraValue+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat 格式化数值
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escapeHelp 转义帮助文本
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+// escapeLabel 转义标签值
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+// countWriter 统计写入的字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("测试指标输出", t, func() {
+		reg := NewRegistry()
+		c := reg.Counter("spider_fetches_total", "抓取的次数", "task", "status")
+		c.Inc("news", "200")
+		c.Add(2, "news", "200")
+		c.Inc("news", "error")
+		So(reg.Counter("spider_fetches_total", "抓取的次数", "task", "status"), ShouldResemble, c)
+
+		g := reg.Gauge("spider_queue_depth", "队列长度", "task")
+		g.Set(3, "a\"b")
+		g.SetFunc(func() float64 { return 7 }, "news")
+
+		h := reg.Histogram("spider_fetch_duration_seconds", "抓取耗时", []float64{1, 0.1}, "task")
+		h.Observe(0.05, "news")
+		h.Observe(0.5, "news")
+		h.Observe(5, "news")
+
+		var buf bytes.Buffer
+		n, err := reg.WriteTo(&buf)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, buf.Len())
+		text := buf.String()
+		So(text, ShouldContainSubstring, "# TYPE spider_fetches_total counter\n")
+		So(text, ShouldContainSubstring, `spider_fetches_total{task="news",status="200"} 3`+"\n")
+		So(text, ShouldContainSubstring, `spider_fetches_total{task="news",status="error"} 1`+"\n")
+		So(text, ShouldContainSubstring, `spider_queue_depth{task="a\"b"} 3`+"\n")
+		So(text, ShouldContainSubstring, `spider_queue_depth{task="news"} 7`+"\n")
+		So(text, ShouldContainSubstring, `spider_fetch_duration_seconds_bucket{task="news",le="0.1"} 1`+"\n")
+		So(text, ShouldContainSubstring, `spider_fetch_duration_seconds_bucket{task="news",le="1"} 2`+"\n")
+		So(text, ShouldContainSubstring, `spider_fetch_duration_seconds_bucket{task="news",le="+Inf"} 3`+"\n")
+		So(text, ShouldContainSubstring, `spider_fetch_duration_seconds_sum{task="news"} 5.55`+"\n")
+		So(text, ShouldContainSubstring, `spider_fetch_duration_seconds_count{task="news"} 3`+"\n")
+		So(strings.Index(text, "spider_fetch_duration_seconds"), ShouldBeLessThan, strings.Index(text, "spider_fetches_total"))
+
+		g.Delete("news")
+		buf.Reset()
+		reg.WriteTo(&buf)
+		So(buf.String(), ShouldNotContainSubstring, `spider_queue_depth{task="news"}`)
+	})
+
+	Convey("测试注册冲突", t, func() {
+		reg := NewRegistry()
+		reg.Counter("spider_rows_total", "数据行", "task")
+		So(func() { reg.Gauge("spider_rows_total", "数据行", "task") }, ShouldPanic)
+		So(func() { reg.Counter("spider_rows_total", "数据行", "rule") }, ShouldPanic)
+		So(func() { reg.Counter("spider_rows_total", "数据行", "task").Inc() }, ShouldPanic)
+	})
+}
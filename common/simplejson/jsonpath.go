@@ -0,0 +1,775 @@
+package simplejson
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Expr 编译后的 JSONPath 表达式
+//
+// 支持的语法：
+//
+//	$                      根节点
+//	.name ['name'] ["name"] 子节点
+//	.* [*]                 全部子节点
+//	..name ..* ..[0]       递归查找
+//	[0] [-1]               数组下标，负数从末尾开始
+//	[start:end:step]       数组切片
+//	[0,2] ['a','b']        联合
+//	[?(@.type=='book')]    过滤，支持 == != < <= > >= =~ && || ! 和括号，@ 为当前节点，$ 为根节点
+//
+// 兼容旧的写法，如 $data.name, $.data.[0]
+type Expr struct {
+	path     string
+	segments []segment
+}
+
+const (
+	segChild     = iota // 子节点
+	segRecursive        // 递归查找
+)
+
+// segment 路径的一段，由一个或多个选择器组成
+type segment struct {
+	kind      int
+	selectors []selector
+}
+
+const (
+	selName     = iota // 名称
+	selWildcard        // 通配符
+	selIndex           // 下标
+	selSlice           // 切片
+	selFilter          // 过滤
+)
+
+// selector 选择器
+type selector struct {
+	kind   int
+	name   string
+	index  int
+	slice  [3]*int
+	filter filterExpr
+}
+
+// Compile 编译 JSONPath 表达式
+func Compile(path string) (*Expr, error) {
+	p := &pathParser{s: path}
+	segs, err := p.parseRoot()
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{path: path, segments: segs}, nil
+}
+
+// MustCompile 编译 JSONPath 表达式，出错时 panic
+func MustCompile(path string) *Expr {
+	e, err := Compile(path)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// String 返回表达式原文
+func (e *Expr) String() string {
+	return e.path
+}
+
+// Definite 判断表达式是否最多只返回一个值，即不含通配符，递归，切片，联合和过滤
+func (e *Expr) Definite() bool {
+	for _, seg := range e.segments {
+		if seg.kind == segRecursive || len(seg.selectors) != 1 {
+			return false
+		}
+		if k := seg.selectors[0].kind; k != selName && k != selIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval 对数据执行表达式，返回全部匹配的值，数组按下标顺序，对象按键排序
+func (e *Expr) Eval(data interface{}) []interface{} {
+	return evalSegments(e.segments, []interface{}{data}, data)
+}
+
+// Query 使用 JSONPath 表达式查询，返回全部匹配的值
+func (t *JSON) Query(path string) ([]interface{}, error) {
+	e, err := Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(t.data), nil
+}
+
+func evalSegments(segs []segment, nodes []interface{}, root interface{}) []interface{} {
+	for _, seg := range segs {
+		var out []interface{}
+		for _, n := range nodes {
+			if seg.kind == segRecursive {
+				for _, d := range descendants(n, nil) {
+					for _, sel := range seg.selectors {
+						out = sel.apply(d, root, out)
+					}
+				}
+				continue
+			}
+			for _, sel := range seg.selectors {
+				out = sel.apply(n, root, out)
+			}
+		}
+		nodes = out
+		if len(nodes) == 0 {
+			break
+		}
+	}
+	return nodes
+}
+
+// descendants 返回节点本身和全部后代，先序遍历
+func descendants(n interface{}, out []interface{}) []interface{} {
+	out = append(out, n)
+	switch v := n.(type) {
+	case []interface{}:
+		for _, c := range v {
+			out = descendants(c, out)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			out = descendants(v[k], out)
+		}
+	}
+	return out
+}
+
+// children 返回节点的子节点，对象按键名排序
+func children(n interface{}) []interface{} {
+	switch v := n.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			out = append(out, v[k])
+		}
+		return out
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// apply 对一个节点执行选择器，匹配的值追加到 out
+func (s *selector) apply(n, root interface{}, out []interface{}) []interface{} {
+	switch s.kind {
+	case selName:
+		if m, ok := n.(map[string]interface{}); ok {
+			if v, ok := m[s.name]; ok {
+				out = append(out, v)
+			}
+		}
+	case selWildcard:
+		out = append(out, children(n)...)
+	case selIndex:
+		if a, ok := n.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(a)
+			}
+			if i >= 0 && i < len(a) {
+				out = append(out, a[i])
+			}
+		}
+	case selSlice:
+		if a, ok := n.([]interface{}); ok {
+			out = append(out, slice(a, s.slice)...)
+		}
+	case selFilter:
+		for _, c := range children(n) {
+			if s.filter.test(c, root) {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// slice 按 start:end:step 截取数组，语义同 Python
+func slice(a []interface{}, sl [3]*int) []interface{} {
+	n := len(a)
+	step := 1
+	if sl[2] != nil {
+		step = *sl[2]
+	}
+	if step == 0 {
+		return nil
+	}
+	norm := func(i int) int {
+		if i < 0 {
+			i += n
+		}
+		return i
+	}
+	var out []interface{}
+	if step > 0 {
+		start, end := 0, n
+		if sl[0] != nil {
+			start = clamp(norm(*sl[0]), 0, n)
+		}
+		if sl[1] != nil {
+			end = clamp(norm(*sl[1]), 0, n)
+		}
+		for i := start; i < end; i += step {
+			out = append(out, a[i])
+		}
+		return out
+	}
+	start, end := n-1, -1
+	if sl[0] != nil {
+		start = clamp(norm(*sl[0]), -1, n-1)
+	}
+	if sl[1] != nil {
+		end = clamp(norm(*sl[1]), -1, n-1)
+	}
+	for i := start; i > end; i += step {
+		out = append(out, a[i])
+	}
+	return out
+}
+
+func clamp(i, min, max int) int {
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
+// filterExpr 过滤表达式
+type filterExpr interface {
+	test(n, root interface{}) bool
+}
+
+type orExpr struct{ left, right filterExpr }
+type andExpr struct{ left, right filterExpr }
+type notExpr struct{ expr filterExpr }
+
+// existExpr 存在判断，如 [?(@.isbn)]，字面量则判断其真假
+type existExpr struct{ operand operand }
+
+// compareExpr 比较表达式
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e orExpr) test(n, root interface{}) bool  { return e.left.test(n, root) || e.right.test(n, root) }
+func (e andExpr) test(n, root interface{}) bool { return e.left.test(n, root) && e.right.test(n, root) }
+func (e notExpr) test(n, root interface{}) bool { return !e.expr.test(n, root) }
+
+func (e existExpr) test(n, root interface{}) bool {
+	v, ok := e.operand.value(n, root)
+	if !ok {
+		return false
+	}
+	if e.operand.path == nil {
+		b, isBool := v.(bool)
+		return !isBool || b
+	}
+	return true
+}
+
+func (e compareExpr) test(n, root interface{}) bool {
+	lv, lok := e.left.value(n, root)
+	rv, rok := e.right.value(n, root)
+	switch e.op {
+	case "==":
+		if !lok || !rok {
+			return !lok && !rok
+		}
+		return equal(lv, rv)
+	case "!=":
+		if !lok || !rok {
+			return lok != rok
+		}
+		return !equal(lv, rv)
+	case "=~":
+		s, ok := lv.(string)
+		return lok && ok && e.right.re != nil && e.right.re.MatchString(s)
+	}
+	if !lok || !rok {
+		return false
+	}
+	c, ok := compare(lv, rv)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare 比较数字或字符串
+func compare(a, b interface{}) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1, true
+			case av > bv:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	}
+	return 0, false
+}
+
+// operand 过滤表达式的操作数，路径或者字面量
+type operand struct {
+	path    []segment      // 路径，为空时是字面量
+	root    bool           // 路径是否从根节点开始
+	literal interface{}    // 字面量
+	re      *regexp.Regexp // 正则，用于 =~
+}
+
+// value 返回操作数的值，路径没有匹配时返回 false
+func (o operand) value(n, root interface{}) (interface{}, bool) {
+	if o.path == nil && !o.root {
+		return o.literal, true
+	}
+	start := n
+	if o.root {
+		start = root
+	}
+	vs := evalSegments(o.path, []interface{}{start}, root)
+	if len(vs) == 0 {
+		return nil, false
+	}
+	return vs[0], true
+}
+
+// pathParser JSONPath 表达式解析器
+type pathParser struct {
+	s   string
+	pos int
+}
+
+func (p *pathParser) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("jsonpath %q at %d: %s", p.s, p.pos, fmt.Sprintf(format, v...))
+}
+
+func (p *pathParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *pathParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *pathParser) skipSpace() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *pathParser) consume(s string) bool {
+	if strings.HasPrefix(p.s[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// parseRoot 解析完整的表达式，必须以 $ 开头
+func (p *pathParser) parseRoot() ([]segment, error) {
+	p.skipSpace()
+	if !p.consume("$") {
+		return nil, p.errorf("must begin with $")
+	}
+	// 兼容 $name 的写法
+	if isNameChar(p.peek()) {
+		segs := []segment{{kind: segChild, selectors: []selector{{kind: selName, name: p.parseName()}}}}
+		more, err := p.parseSegments(false)
+		return append(segs, more...), err
+	}
+	segs, err := p.parseSegments(false)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return segs, nil
+}
+
+// parseSegments 解析路径段，inFilter 时遇到不能继续的字符就停止
+func (p *pathParser) parseSegments(inFilter bool) ([]segment, error) {
+	var segs []segment
+	for !p.eof() {
+		switch {
+		case p.consume(".."):
+			seg := segment{kind: segRecursive}
+			switch {
+			case p.consume("*"):
+				seg.selectors = []selector{{kind: selWildcard}}
+			case p.peek() == '[':
+				sels, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+			case isNameChar(p.peek()):
+				seg.selectors = []selector{{kind: selName, name: p.parseName()}}
+			default:
+				return nil, p.errorf("expect name after ..")
+			}
+			segs = append(segs, seg)
+		case p.consume("."):
+			switch {
+			case p.consume("*"):
+				segs = append(segs, segment{kind: segChild, selectors: []selector{{kind: selWildcard}}})
+			case p.peek() == '[':
+				// 兼容 $.data.[0] 的写法，由下一轮解析
+			case isNameChar(p.peek()):
+				segs = append(segs, segment{kind: segChild, selectors: []selector{{kind: selName, name: p.parseName()}}})
+			default:
+				return nil, p.errorf("expect name after .")
+			}
+		case p.peek() == '[':
+			sels, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, segment{kind: segChild, selectors: sels})
+		default:
+			if inFilter {
+				return segs, nil
+			}
+			return nil, p.errorf("unexpected %q", p.s[p.pos:])
+		}
+	}
+	return segs, nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '-' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *pathParser) parseName() string {
+	start := p.pos
+	for !p.eof() && isNameChar(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// parseBracket 解析 [...] 中的选择器，多个以逗号分隔
+func (p *pathParser) parseBracket() ([]selector, error) {
+	p.pos++ // [
+	var sels []selector
+	for {
+		p.skipSpace()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		p.skipSpace()
+		if p.consume(",") {
+			continue
+		}
+		if p.consume("]") {
+			return sels, nil
+		}
+		return nil, p.errorf("expect , or ]")
+	}
+}
+
+func (p *pathParser) parseSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return selector{kind: selWildcard}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return selector{kind: selName, name: s}, err
+	case c == '?':
+		p.pos++
+		p.skipSpace()
+		paren := p.consume("(")
+		f, err := p.parseOr()
+		if err != nil {
+			return selector{}, err
+		}
+		p.skipSpace()
+		if paren && !p.consume(")") {
+			return selector{}, p.errorf("expect )")
+		}
+		return selector{kind: selFilter, filter: f}, nil
+	case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+		return p.parseIndexOrSlice()
+	case isNameChar(c):
+		// 兼容 [name] 的写法
+		return selector{kind: selName, name: p.parseName()}, nil
+	}
+	return selector{}, p.errorf("unexpected %q", p.s[p.pos:])
+}
+
+func (p *pathParser) parseInt() (*int, error) {
+	p.skipSpace()
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for !p.eof() && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return nil, p.errorf("invalid number %q", p.s[start:p.pos])
+	}
+	return &i, nil
+}
+
+func (p *pathParser) parseIndexOrSlice() (selector, error) {
+	var parts [3]*int
+	var err error
+	for i := 0; i < 3; i++ {
+		if parts[i], err = p.parseInt(); err != nil {
+			return selector{}, err
+		}
+		p.skipSpace()
+		if i == 0 && p.peek() != ':' {
+			if parts[0] == nil {
+				return selector{}, p.errorf("expect index")
+			}
+			return selector{kind: selIndex, index: *parts[0]}, nil
+		}
+		if i < 2 && !p.consume(":") {
+			break
+		}
+	}
+	return selector{kind: selSlice, slice: parts}, nil
+}
+
+func (p *pathParser) parseString() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos++
+		if c == quote {
+			return b.String(), nil
+		}
+		if c == '\\' && !p.eof() {
+			c = p.s[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			}
+		}
+		b.WriteByte(c)
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *pathParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+}
+
+func (p *pathParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+}
+
+func (p *pathParser) parseUnary() (filterExpr, error) {
+	p.skipSpace()
+	if p.peek() == '!' && !strings.HasPrefix(p.s[p.pos:], "!=") {
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+	if p.consume("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expect )")
+		}
+		return e, nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		p.skipSpace()
+		var right operand
+		if op == "=~" {
+			right, err = p.parseRegexp()
+		} else {
+			right, err = p.parseOperand()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return compareExpr{op: op, left: left, right: right}, nil
+	}
+	return existExpr{left}, nil
+}
+
+func (p *pathParser) parseOperand() (operand, error) {
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == '@' || c == '$':
+		p.pos++
+		segs, err := p.parseSegments(true)
+		if err != nil {
+			return operand{}, err
+		}
+		if segs == nil {
+			segs = []segment{}
+		}
+		return operand{path: segs, root: c == '$'}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return operand{literal: s}, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for !p.eof() && (p.s[p.pos] == '.' || p.s[p.pos] == 'e' || p.s[p.pos] == 'E' || (p.s[p.pos] >= '0' && p.s[p.pos] <= '9')) {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return operand{}, p.errorf("invalid number %q", p.s[start:p.pos])
+		}
+		return operand{literal: f}, nil
+	case p.consume("true"):
+		return operand{literal: true}, nil
+	case p.consume("false"):
+		return operand{literal: false}, nil
+	case p.consume("null"):
+		return operand{literal: nil}, nil
+	}
+	return operand{}, p.errorf("unexpected %q", p.s[p.pos:])
+}
+
+// parseRegexp 解析 /pattern/flags 形式的正则，支持 i 标记
+func (p *pathParser) parseRegexp() (operand, error) {
+	if p.peek() != '/' {
+		o, err := p.parseOperand()
+		if err != nil {
+			return o, err
+		}
+		s, ok := o.literal.(string)
+		if !ok {
+			return o, p.errorf("=~ expect regexp")
+		}
+		o.re, err = regexp.Compile(s)
+		return o, err
+	}
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.s[p.pos] != '/' {
+		if p.s[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.eof() {
+		return operand{}, p.errorf("unterminated regexp")
+	}
+	pattern := strings.Replace(p.s[start:p.pos], `\/`, "/", -1)
+	p.pos++
+	if p.consume("i") {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return operand{}, p.errorf("invalid regexp: %v", err)
+	}
+	return operand{literal: pattern, re: re}, nil
+}
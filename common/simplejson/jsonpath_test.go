@@ -0,0 +1,74 @@
+package simplejson
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const storeJSON = `{"store": {
+	"book": [
+		{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+		{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+		{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+		{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+	],
+	"bicycle": {"color": "red", "price": 19.95}
+}}`
+
+func TestJSONPath1(t *testing.T) {
+	j, _ := New([]byte(storeJSON))
+	query := func(path string) []interface{} {
+		v, err := j.Query(path)
+		So(err, ShouldBeNil)
+		return v
+	}
+
+	Convey("测试JSONPath基本路径", t, func() {
+		So(query("$.store.book[0].author"), ShouldResemble, []interface{}{"Nigel Rees"})
+		So(query("$['store']['bicycle']['color']"), ShouldResemble, []interface{}{"red"})
+		So(query("$.store.book[-1].title"), ShouldResemble, []interface{}{"The Lord of the Rings"})
+		So(query("$.store.nothing"), ShouldBeEmpty)
+	})
+	Convey("测试JSONPath兼容旧写法", t, func() {
+		So(query("$store.bicycle.color"), ShouldResemble, []interface{}{"red"})
+		So(query("$.store.book.[1].price"), ShouldResemble, []interface{}{12.99})
+		So(query("$.store.book[0].author")[0], ShouldEqual, j.Path("$.store.book[0].author").MustString())
+	})
+	Convey("测试JSONPath通配符和递归", t, func() {
+		So(query("$.store.book[*].author"), ShouldHaveLength, 4)
+		So(query("$..author"), ShouldHaveLength, 4)
+		So(query("$.store.*"), ShouldHaveLength, 2)
+		So(query("$..price"), ShouldHaveLength, 5)
+		So(query("$..book[2].title"), ShouldResemble, []interface{}{"Moby Dick"})
+	})
+	Convey("测试JSONPath切片和联合", t, func() {
+		So(query("$.store.book[0:2].price"), ShouldResemble, []interface{}{8.95, 12.99})
+		So(query("$.store.book[-2:].price"), ShouldResemble, []interface{}{8.99, 22.99})
+		So(query("$.store.book[::2].price"), ShouldResemble, []interface{}{8.95, 8.99})
+		So(query("$.store.book[0,3].price"), ShouldResemble, []interface{}{8.95, 22.99})
+		So(query("$.store.bicycle['color','price']"), ShouldResemble, []interface{}{"red", 19.95})
+	})
+	Convey("测试JSONPath过滤", t, func() {
+		So(query("$..book[?(@.isbn)].title"), ShouldResemble, []interface{}{"Moby Dick", "The Lord of the Rings"})
+		So(query("$..book[?(@.price < 10)].price"), ShouldResemble, []interface{}{8.95, 8.99})
+		So(query("$..book[?(@.category=='reference')].author"), ShouldResemble, []interface{}{"Nigel Rees"})
+		So(query(`$..book[?(@.category=="fiction" && @.price > 20)].title`), ShouldResemble, []interface{}{"The Lord of the Rings"})
+		So(query("$..book[?(!@.isbn || @.price >= 22)].price"), ShouldResemble, []interface{}{8.95, 12.99, 22.99})
+		So(query("$..book[?(@.author =~ /tolkien/i)].price"), ShouldResemble, []interface{}{22.99})
+		So(query("$..book[?(@.price > $.store.bicycle.price)].price"), ShouldResemble, []interface{}{22.99})
+	})
+	Convey("测试JSONPath确定路径判断", t, func() {
+		So(MustCompile("$.store.book[0].title").Definite(), ShouldBeTrue)
+		So(MustCompile("$..title").Definite(), ShouldBeFalse)
+		So(MustCompile("$.store.book[*]").Definite(), ShouldBeFalse)
+	})
+	Convey("测试JSONPath错误", t, func() {
+		_, err := Compile("store.book")
+		So(err, ShouldNotBeNil)
+		_, err = Compile("$.store.book[?(@.price <")
+		So(err, ShouldNotBeNil)
+		_, err = Compile("$.store.book[0")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,250 @@
+package util
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToMarkdown 将HTML片段转换为 Markdown
+// 支持标题，段落，换行，粗体，斜体，行内代码，代码块，链接，图片，有序和无序列表(可嵌套)，引用，分隔线和表格，
+// 脚本和样式表被丢弃，其他标签只保留文本
+func HTMLToMarkdown(s string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return s
+	}
+	w := new(markdownWriter)
+	for _, n := range nodes {
+		w.node(n)
+	}
+	return w.String()
+}
+
+// markdownWriter 转换 Markdown 时的状态
+type markdownWriter struct {
+	buf   strings.Builder
+	lists []int // 列表的嵌套，有序列表为当前序号，无序列表为 0
+}
+
+var (
+	markdownBlankRegexp = regexp.MustCompile(`[ \t\r\n]+`)
+	markdownLinesRegexp = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+	markdownSpaceRegexp = regexp.MustCompile(`(?m)[ \t]+\n`)
+)
+
+// String 返回整理后的 Markdown，合并多余的空行
+func (w *markdownWriter) String() string {
+	s := markdownSpaceRegexp.ReplaceAllStringFunc(w.buf.String(), func(m string) string {
+		if strings.HasPrefix(m, "  ") {
+			return "  \n" // 保留换行标记
+		}
+		return "\n"
+	})
+	s = markdownLinesRegexp.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+// block 开始或结束一个块，保证前面有空行
+func (w *markdownWriter) block() {
+	if w.buf.Len() > 0 {
+		w.buf.WriteString("\n\n")
+	}
+}
+
+// children 转换全部子节点
+func (w *markdownWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+// inline 转换子节点为一行文本
+func (w *markdownWriter) inline(n *html.Node) string {
+	sub := new(markdownWriter)
+	sub.children(n)
+	return strings.TrimSpace(markdownBlankRegexp.ReplaceAllString(sub.buf.String(), " "))
+}
+
+// node 转换一个节点
+func (w *markdownWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if p := n.Parent; p != nil && (p.DataAtom == atom.Ul || p.DataAtom == atom.Ol) {
+			return // 列表项之间的空白
+		}
+		w.buf.WriteString(markdownBlankRegexp.ReplaceAllString(n.Data, " "))
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Head, atom.Template:
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.block()
+		level, _ := strconv.Atoi(n.Data[1:])
+		w.buf.WriteString(strings.Repeat("#", level) + " " + w.inline(n))
+		w.block()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Main, atom.Aside, atom.Figure, atom.Dl:
+		w.block()
+		w.children(n)
+		w.block()
+	case atom.Br:
+		w.buf.WriteString("  \n")
+	case atom.Hr:
+		w.block()
+		w.buf.WriteString("---")
+		w.block()
+	case atom.Strong, atom.B:
+		if text := w.inline(n); text != "" {
+			w.buf.WriteString("**" + text + "**")
+		}
+	case atom.Em, atom.I:
+		if text := w.inline(n); text != "" {
+			w.buf.WriteString("*" + text + "*")
+		}
+	case atom.Del, atom.S, atom.Strike:
+		if text := w.inline(n); text != "" {
+			w.buf.WriteString("~~" + text + "~~")
+		}
+	case atom.Code:
+		w.buf.WriteString("`" + nodeText(n) + "`")
+	case atom.Pre:
+		w.block()
+		w.buf.WriteString("```\n")
+		w.buf.WriteString(strings.Trim(nodeText(n), "\n"))
+		w.buf.WriteString("\n```")
+		w.block()
+	case atom.A:
+		text := w.inline(n)
+		href := nodeAttr(n, "href")
+		if href == "" || strings.HasPrefix(href, "javascript:") {
+			w.buf.WriteString(text)
+		} else {
+			w.buf.WriteString("[" + text + "](" + href + ")")
+		}
+	case atom.Img:
+		if src := nodeAttr(n, "src"); src != "" {
+			w.buf.WriteString("![" + nodeAttr(n, "alt") + "](" + src + ")")
+		}
+	case atom.Ul, atom.Ol:
+		if len(w.lists) == 0 {
+			w.block()
+		} else {
+			w.buf.WriteString("\n")
+		}
+		start := 0
+		if n.DataAtom == atom.Ol {
+			start = 1
+			if v, err := strconv.Atoi(nodeAttr(n, "start")); err == nil {
+				start = v
+			}
+		}
+		w.lists = append(w.lists, start)
+		w.children(n)
+		w.lists = w.lists[:len(w.lists)-1]
+		if len(w.lists) == 0 {
+			w.block()
+		}
+	case atom.Li:
+		depth := len(w.lists)
+		marker := "- "
+		if depth > 0 && w.lists[depth-1] > 0 {
+			marker = strconv.Itoa(w.lists[depth-1]) + ". "
+			w.lists[depth-1]++
+		}
+		if depth > 1 {
+			w.buf.WriteString(strings.Repeat("  ", depth-1))
+		}
+		w.buf.WriteString(marker)
+		sub := &markdownWriter{lists: w.lists}
+		sub.children(n)
+		w.buf.WriteString(strings.TrimSpace(sub.buf.String()))
+		w.buf.WriteString("\n")
+	case atom.Blockquote:
+		w.block()
+		sub := new(markdownWriter)
+		sub.children(n)
+		for _, line := range strings.Split(sub.String(), "\n") {
+			w.buf.WriteString("> " + line + "\n")
+		}
+		w.block()
+	case atom.Table:
+		w.block()
+		w.table(n)
+		w.block()
+	default:
+		w.children(n)
+	}
+}
+
+// table 转换表格，第一行作为表头
+func (w *markdownWriter) table(n *html.Node) {
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom == atom.Tr {
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						row = append(row, strings.Replace(w.inline(cell), "|", `\|`, -1))
+					}
+				}
+				rows = append(rows, row)
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return
+	}
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		w.buf.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			w.buf.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+}
+
+// nodeText 返回节点的全部文本
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
+
+// nodeAttr 返回节点的属性值
+func nodeAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
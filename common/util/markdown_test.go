@@ -0,0 +1,23 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	Convey("测试HTML转换为Markdown", t, func() {
+		So(HTMLToMarkdown(`<h2>标题</h2><p>一段 <b>粗体</b> 和 <a href="/a">链接</a><br>换行</p><script>x()</script>`),
+			ShouldEqual, "## 标题\n\n一段 **粗体** 和 [链接](/a)  \n换行")
+		So(HTMLToMarkdown(`<ul><li>a</li><li>b<ol><li>c</li><li>d</li></ol></li></ul>`),
+			ShouldEqual, "- a\n- b\n  1. c\n  2. d")
+		So(HTMLToMarkdown(`<pre><code>if a {
+	b()
+}</code></pre><img src="/i.png" alt="图">`),
+			ShouldEqual, "```\nif a {\n\tb()\n}\n```\n\n![图](/i.png)")
+		So(HTMLToMarkdown(`<table><tr><th>名称</th><th>价格</th></tr><tr><td>a</td><td>1</td></tr></table>`),
+			ShouldEqual, "| 名称 | 价格 |\n| --- | --- |\n| a | 1 |")
+		So(HTMLToMarkdown(`<blockquote><p>引用</p></blockquote>`), ShouldEqual, "> 引用")
+	})
+}
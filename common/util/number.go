@@ -0,0 +1,271 @@
+package util
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// decimalCommaLocales 以逗号作为小数点的语言
+var decimalCommaLocales = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "pt": true, "nl": true, "ru": true, "pl": true,
+	"sv": true, "da": true, "fi": true, "nb": true, "no": true, "cs": true, "tr": true, "id": true, "vi": true,
+}
+
+// numberRegexp 文本中的数字，可以带千分位分隔符和中文单位
+var numberRegexp = regexp.MustCompile(`[-\x{2212}]?\d(?:[\d.,'\x{2019}\x{a0}\x{202f} ]*\d)?\s*[万亿]?`)
+
+// currencySymbols 货币符号和名称，较长的在前
+var currencySymbols = []struct{ symbol, code string }{
+	{"US$", "USD"}, {"HK$", "HKD"}, {"NT$", "TWD"}, {"A$", "AUD"}, {"C$", "CAD"}, {"S$", "SGD"},
+	{"人民币", "CNY"}, {"RMB", "CNY"}, {"港币", "HKD"}, {"美元", "USD"}, {"欧元", "EUR"}, {"日元", "JPY"},
+	{"$", "USD"}, {"€", "EUR"}, {"£", "GBP"}, {"円", "JPY"}, {"¥", "CNY"}, {"￥", "CNY"}, {"元", "CNY"},
+	{"₩", "KRW"}, {"₹", "INR"}, {"₽", "RUB"}, {"₺", "TRY"}, {"₫", "VND"}, {"฿", "THB"},
+}
+
+// currencyCodeRegexp 文本中的货币代码
+var currencyCodeRegexp = regexp.MustCompile(`\b(USD|EUR|GBP|CNY|JPY|HKD|TWD|KRW|INR|RUB|AUD|CAD|SGD|CHF|SEK|NOK|DKK|PLN|TRY|VND|THB|BRL|MXN)\b`)
+
+// ParseNumber 提取文本中的第一个数字，按语言处理千分位和小数点
+// locale 为空时自动判断：同时有逗号和点时，后出现的是小数点；只有逗号且其后正好三位数字时是千分位
+// locale 如 en, de, fr-FR，de, fr 等语言以逗号为小数点，空格和撇号总是作为千分位
+// 支持中文单位 万, 亿 和全角数字，如 "阅读 1.2万" 返回 12000
+func ParseNumber(s, locale string) (float64, error) {
+	s = fullWidthDigits(s)
+	m := numberRegexp.FindString(s)
+	if m == "" {
+		return 0, errors.New("no number found")
+	}
+	m = strings.Replace(m, "\u2212", "-", 1)
+	multiplier := 1.0
+	if strings.HasSuffix(m, "万") {
+		multiplier, m = 1e4, strings.TrimSpace(strings.TrimSuffix(m, "万"))
+	} else if strings.HasSuffix(m, "亿") {
+		multiplier, m = 1e8, strings.TrimSpace(strings.TrimSuffix(m, "亿"))
+	}
+	for _, sep := range []string{"'", "\u2019", "\u00a0", "\u202f", " "} {
+		m = strings.Replace(m, sep, "", -1)
+	}
+
+	lang := strings.ToLower(locale)
+	if p := strings.IndexAny(lang, "-_"); p >= 0 {
+		lang = lang[:p]
+	}
+	comma, dot := strings.LastIndex(m, ","), strings.LastIndex(m, ".")
+	decimalComma := false
+	switch {
+	case locale != "":
+		decimalComma = decimalCommaLocales[lang]
+	case comma >= 0 && dot >= 0:
+		decimalComma = comma > dot
+	case comma >= 0:
+		decimalComma = len(m)-comma-1 != 3 || strings.Count(m, ",") == 1 && strings.HasPrefix(strings.TrimLeft(m, "-"), "0,")
+	}
+	if decimalComma {
+		m = strings.Replace(m, ".", "", -1)
+		m = strings.Replace(m, ",", ".", 1)
+	} else {
+		m = strings.Replace(m, ",", "", -1)
+	}
+	if strings.Count(m, ".") > 1 {
+		// 点作为千分位，如 1.234.567
+		m = strings.Replace(m, ".", "", -1)
+	}
+	n, err := strconv.ParseFloat(m, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
+// ParsePrice 提取文本中的价格和货币代码(ISO 4217)，没有货币标识时货币代码为空
+// 如 "€1.234,50" 返回 1234.5, EUR；"¥ 99" 返回 99, CNY
+func ParsePrice(s, locale string) (float64, string, error) {
+	n, err := ParseNumber(s, locale)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, Currency(s), nil
+}
+
+// Currency 返回文本中的货币代码(ISO 4217)，没有时返回空字符串
+func Currency(s string) string {
+	if m := currencyCodeRegexp.FindString(strings.ToUpper(s)); m != "" {
+		return m
+	}
+	for _, c := range currencySymbols {
+		if strings.Contains(s, c.symbol) {
+			if c.code == "CNY" && strings.Contains(s, "円") {
+				return "JPY"
+			}
+			return c.code
+		}
+	}
+	return ""
+}
+
+// FormatNumber 将数字格式化为最短的十进制字符串
+func FormatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// fullWidthDigits 将全角数字和符号转为半角
+func fullWidthDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '０' && r <= '９':
+			return r - '０' + '0'
+		case r == '．':
+			return '.'
+		case r == '，':
+			return ','
+		case r == '－':
+			return '-'
+		}
+		return r
+	}, s)
+}
+
+// chineseDigits 中文数字
+var chineseDigits = map[rune]int64{
+	'零': 0, '〇': 0, '○': 0, 'Ｏ': 0,
+	'一': 1, '壹': 1, '幺': 1,
+	'二': 2, '贰': 2, '两': 2, '俩': 2,
+	'三': 3, '叁': 3,
+	'四': 4, '肆': 4,
+	'五': 5, '伍': 5,
+	'六': 6, '陆': 6,
+	'七': 7, '柒': 7,
+	'八': 8, '捌': 8,
+	'九': 9, '玖': 9,
+}
+
+// chineseUnits 中文数字单位，万和亿是节的单位
+var chineseUnits = map[rune]int64{
+	'十': 10, '拾': 10,
+	'百': 100, '佰': 100,
+	'千': 1000, '仟': 1000,
+	'万': 1e4, '萬': 1e4,
+	'亿': 1e8, '億': 1e8,
+}
+
+// ChineseToNumber 将中文数字转为整数，如 一万二千三百零五 返回 12305，
+// 没有单位时按位读，如 二〇二四 返回 2024；
+// 百、千、万、亿 后面的单个数字为下一级的单位，如 一万二 返回 12000，三千五 返回 3500，
+// 有单位时两个非零数字相邻(如 九九八十一)返回错误
+func ChineseToNumber(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty chinese number")
+	}
+	hasUnit := false
+	for _, r := range s {
+		if _, ok := chineseUnits[r]; ok {
+			hasUnit = true
+		} else if _, ok := chineseDigits[r]; !ok {
+			return 0, errors.New("invalid chinese number: " + s)
+		}
+	}
+	if !hasUnit {
+		var n int64
+		for _, r := range s {
+			n = n*10 + chineseDigits[r]
+		}
+		return n, nil
+	}
+
+	var total, section, digit, lastUnit int64
+	hasDigit := false
+	for _, r := range s {
+		if d, ok := chineseDigits[r]; ok {
+			if hasDigit {
+				if digit != 0 {
+					return 0, errors.New("invalid chinese number: " + s)
+				}
+				lastUnit = 0 // 零 之后的数字为个位，一千零五 = 1005
+			}
+			digit, hasDigit = d, true
+			continue
+		}
+		unit := chineseUnits[r]
+		if !hasDigit && (unit < 1e4 || section == 0 && total == 0) {
+			digit = 1 // 十二 = 12，万 = 10000
+		}
+		switch unit {
+		case 1e8:
+			total = (total + section + digit) * unit
+			section = 0
+		case 1e4:
+			total += (section + digit) * unit
+			section = 0
+		default:
+			section += digit * unit
+		}
+		digit, hasDigit = 0, false
+		lastUnit = unit
+	}
+	if hasDigit && lastUnit >= 100 {
+		digit *= lastUnit / 10 // 一万二 = 12000，三千五 = 3500
+	}
+	return total + section + digit, nil
+}
+
+// chineseNumberRegexp 文本中的中文数字，以数字或十开头
+var chineseNumberRegexp = regexp.MustCompile(`[零〇○一壹幺二贰两三叁四肆五伍六陆七柒八捌九玖十拾][零〇○一壹幺二贰两三叁四肆五伍六陆七柒八捌九玖十拾百佰千仟万萬亿億]*`)
+
+// chineseMeasureWords 数字后面的单位和量词，单个中文数字在这些词之前时替换
+var chineseMeasureWords = []string{
+	"章", "页", "回", "节", "集", "卷", "篇", "期", "季", "部", "楼", "层", "岁", "年", "月", "日", "号",
+	"元", "块", "斤", "米", "次", "人", "个", "位", "名", "条", "件", "张", "本", "天", "周",
+	"小时", "分钟", "秒", "公里", "千克", "克",
+}
+
+// ReplaceChineseNumbers 将文本中的中文数字替换为阿拉伯数字，如 第十二章 替换为 第12章
+//
+// 只替换数字上下文中的中文数字：第 之后，两个及以上含 十百千万亿 的字符，含 〇/零 的按位读的数字(如 二〇二四)，
+// 或单位、量词之前的单个数字，单独的 一 只在 第 之后替换，统一、十分、一些、万一、一个 这样的词不变，
+// 没有单位的相邻数字是约数或成语(如 七八个人、三五天、三三两两)，不替换
+func ReplaceChineseNumbers(s string) string {
+	locs := chineseNumberRegexp.FindAllStringIndex(s, -1)
+	if len(locs) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		if !isChineseNumberContext(s, loc[0], loc[1]) {
+			continue
+		}
+		n, err := ChineseToNumber(s[loc[0]:loc[1]])
+		if err != nil {
+			continue
+		}
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(strconv.FormatInt(n, 10))
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// isChineseNumberContext 判断 s[start:end] 的中文数字是否在数字上下文中
+func isChineseNumberContext(s string, start, end int) bool {
+	m := s[start:end]
+	if strings.HasSuffix(s[:start], "第") {
+		return true
+	}
+	if utf8.RuneCountInString(m) >= 2 {
+		return strings.ContainsAny(m, "十拾百佰千仟万萬亿億〇○零")
+	}
+	if m == "一" {
+		return false
+	}
+	for _, w := range chineseMeasureWords {
+		if strings.HasPrefix(s[end:], w) {
+			return true
+		}
+	}
+	return false
+}
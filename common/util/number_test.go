@@ -0,0 +1,93 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseNumber(t *testing.T) {
+	Convey("测试数字提取", t, func() {
+		cases := []struct {
+			s, locale string
+			n         float64
+		}{
+			{"1,234.50", "", 1234.5},
+			{"1.234,50", "", 1234.5},
+			{"1,5", "", 1.5},
+			{"12,345,678", "", 12345678},
+			{"1.234.567", "", 1234567},
+			{"1 234,50 €", "fr", 1234.5},
+			{"1.234", "de", 1234},
+			{"CHF 1'234.50", "", 1234.5},
+			{"阅读 1.2万", "", 12000},
+			{"３．５亿", "", 350000000},
+			{"-42 items", "", -42},
+		}
+		for _, c := range cases {
+			n, err := ParseNumber(c.s, c.locale)
+			So(err, ShouldBeNil)
+			So(n, ShouldAlmostEqual, c.n)
+		}
+		_, err := ParseNumber("无", "")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestParsePrice(t *testing.T) {
+	Convey("测试价格和货币提取", t, func() {
+		n, c, err := ParsePrice("€1.234,50", "")
+		So(err, ShouldBeNil)
+		So(n, ShouldAlmostEqual, 1234.5)
+		So(c, ShouldEqual, "EUR")
+		n, c, _ = ParsePrice("￥1,299.00", "")
+		So(n, ShouldAlmostEqual, 1299)
+		So(c, ShouldEqual, "CNY")
+		_, c, _ = ParsePrice("US$ 5", "")
+		So(c, ShouldEqual, "USD")
+		_, c, _ = ParsePrice("¥500 円", "")
+		So(c, ShouldEqual, "JPY")
+		_, c, _ = ParsePrice("12 gbp", "")
+		So(c, ShouldEqual, "GBP")
+		_, c, _ = ParsePrice("12", "")
+		So(c, ShouldEqual, "")
+	})
+}
+
+func TestChineseToNumber(t *testing.T) {
+	Convey("测试中文数字转换", t, func() {
+		cases := map[string]int64{
+			"十":        10,
+			"十二":       12,
+			"二十":       20,
+			"一百零五":     105,
+			"一万二千三百零五": 12305,
+			"两千万":      20000000,
+			"一亿二千万":    120000000,
+			"一万亿":      1000000000000,
+			"二〇二四":     2024,
+			"壹佰贰拾叁":    123,
+			"一万二":      12000,
+			"三千五":      3500,
+			"两百五":      250,
+			"一亿二":      120000000,
+			"一万零二":     10002,
+			"一千零五":     1005,
+		}
+		for s, n := range cases {
+			v, err := ChineseToNumber(s)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, n)
+		}
+		for _, s := range []string{"十二个", "九九八十一", "一万二三", "三百五零"} {
+			_, err := ChineseToNumber(s)
+			So(err, ShouldNotBeNil)
+		}
+		So(ReplaceChineseNumbers("第十二章 共三百页"), ShouldEqual, "第12章 共300页")
+		So(ReplaceChineseNumbers("第一回 十分钟 三个人 二〇二四年"), ShouldEqual, "第1回 10分钟 3个人 2024年")
+		So(ReplaceChineseNumbers("第七八回 三十五天 一万二 零零七"), ShouldEqual, "第78回 35天 12000 7")
+		for _, s := range []string{"统一", "十分", "一些", "万一", "一个问题", "一起", "九州", "七八个人", "三五天后", "三三两两", "一二"} {
+			So(ReplaceChineseNumbers(s), ShouldEqual, s)
+		}
+	})
+}
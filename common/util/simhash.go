@@ -0,0 +1,84 @@
+package util
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// SimHash 计算文本的64位 SimHash 指纹，相似的文本指纹的海明距离小
+// 英文等按单词分词，中日韩文字按相邻两个字分词，不区分大小写，忽略标点和纯数字(时间，计数等)
+func SimHash(text string) uint64 {
+	var weights [64]int
+	features := 0
+	for token, n := range simHashTokens(text) {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		v := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if v&(1<<uint(i)) != 0 {
+				weights[i] += n
+			} else {
+				weights[i] -= n
+			}
+		}
+		features++
+	}
+	if features == 0 {
+		return 0
+	}
+	var fp uint64
+	for i := 0; i < 64; i++ {
+		if weights[i] > 0 {
+			fp |= 1 << uint(i)
+		}
+	}
+	return fp
+}
+
+// HammingDistance 返回两个指纹不同的位数
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// simHashTokens 分词并统计词频
+func simHashTokens(text string) map[string]int {
+	tokens := make(map[string]int)
+	var word []rune
+	var prev rune // 上一个中日韩文字
+	flush := func() {
+		for _, r := range word {
+			if !unicode.IsDigit(r) {
+				tokens[string(word)]++
+				break
+			}
+		}
+		word = word[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flush()
+			if prev != 0 {
+				tokens[string([]rune{prev, r})]++
+			} else {
+				tokens[string(r)]++
+			}
+			prev = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prev = 0
+	}
+	flush()
+	return tokens
+}
+
+// isCJK 是否中日韩文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
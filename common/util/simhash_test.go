@@ -0,0 +1,36 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSimHash(t *testing.T) {
+	Convey("测试 SimHash 指纹", t, func() {
+		en := "The Go programming language is an open source project to make programmers more productive. " +
+			"Go is expressive, concise, clean, and efficient. Its concurrency mechanisms make it easy to write programs " +
+			"that get the most out of multicore and networked machines, while its novel type system enables flexible and modular program construction. " +
+			"Go compiles quickly to machine code yet has the convenience of garbage collection and the power of run-time reflection."
+		zh := "Go 是一个开源的编程语言，它能让构造简单、可靠且高效的软件变得容易。Go 语言的并发机制让编写能够充分利用多核和网络机器的程序变得容易，" +
+			"而它新颖的类型系统则使得构建灵活的模块化程序成为可能。Go 代码编译成机器码不仅非常迅速，还具有方便的垃圾收集机制和强大的运行时反射机制。"
+
+		So(SimHash(""), ShouldEqual, 0)
+		So(SimHash(en), ShouldEqual, SimHash(en))
+		So(SimHash(en), ShouldEqual, SimHash("  "+en+" !"))
+
+		// 只有时间和广告不同
+		So(HammingDistance(SimHash(en+" Updated 2024-01-02 10:00"), SimHash(en+" Updated 2024-03-05 18:30")), ShouldBeLessThanOrEqualTo, 3)
+		So(HammingDistance(SimHash(zh+"发布于 3小时前"), SimHash(zh+"发布于 5小时前")), ShouldBeLessThanOrEqualTo, 3)
+
+		// 不同的文本
+		So(HammingDistance(SimHash(en), SimHash(zh)), ShouldBeGreaterThan, 10)
+		So(HammingDistance(SimHash(en), SimHash("Python is a programming language that lets you work quickly and integrate systems more effectively.")), ShouldBeGreaterThan, 10)
+	})
+
+	Convey("测试海明距离", t, func() {
+		So(HammingDistance(0, 0), ShouldEqual, 0)
+		So(HammingDistance(0xff, 0x0f), ShouldEqual, 4)
+		So(HammingDistance(0, ^uint64(0)), ShouldEqual, 64)
+	})
+}
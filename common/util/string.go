@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 const (
@@ -206,3 +207,10 @@ func Concat(args ...string) string {
 	}
 	return buf.String()
 }
+
+// NormalizeSpace 将连续的空白(含全角空格，不换行空格和零宽空格)合并为一个空格，并去掉两端的空白
+func NormalizeSpace(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '\u3000' || r == '\u200b'
+	}), " ")
+}
@@ -1,7 +1,10 @@
 package util
 
 import (
+	"errors"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -57,3 +60,143 @@ func FriendlyTime(t time.Time) string {
 	}
 	return t.Format("2006-01-02")
 }
+
+// timeLayouts ParseTime 支持的时间格式，没有年份的格式使用当前年份
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"2006.01.02 15:04:05",
+	"2006.01.02 15:04",
+	"2006.01.02",
+	"2006年1月2日 15:04:05",
+	"2006年1月2日 15:04",
+	"2006年1月2日15:04",
+	"2006年1月2日",
+	"2006年1月",
+	"1月2日 15:04",
+	"1月2日15:04",
+	"1月2日",
+	"01-02 15:04",
+	"01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC850,
+	time.ANSIC,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Jan 2, 2006 15:04",
+	"Jan 2, 2006",
+	"January 2, 2006 15:04",
+	"January 2, 2006",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"2 January 2006",
+}
+
+// relativeTimeRegexp 相对时间，如 3小时前, 5 minutes ago
+var relativeTimeRegexp = regexp.MustCompile(`^(\d+|[零一二两三四五六七八九十百]+|半|an?|one)\s*(秒|分钟|分|小时|个小时|钟头|个钟头|天|日|周|星期|个星期|个月|月|年|seconds?|secs?|minutes?|mins?|hours?|hrs?|days?|weeks?|months?|years?)\s*(前|之前|以前|ago)$`)
+
+// dayOffsetRegexp 以天表示的时间，如 昨天 12:30, yesterday 08:00
+var dayOffsetRegexp = regexp.MustCompile(`^(今天|今日|昨天|昨日|前天|大前天|today|yesterday)\s*(\d{1,2}:\d{2}(?::\d{2})?)?$`)
+
+// ParseTime 解析网页中常见的时间文本，支持相对时间和中文时间
+//
+//	刚刚, just now, 3秒前, 5分钟前, 半小时前, 2小时前, 3天前, 1周前, 2个月前, 1年前, 5 minutes ago, an hour ago
+//	今天 15:04, 昨天, 前天 08:00, yesterday 12:30
+//	2006-01-02 15:04:05, 2006/01/02, 2006年1月2日, 1月2日 15:04 等，见 timeLayouts
+//	10位或13位的时间戳
+//
+// now 为相对时间的基准，没有时区的时间使用 now 的时区，没有年份的时间晚于 now 时认为是去年
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(fullWidthDigits(s))
+	lower := strings.ToLower(s)
+	loc := now.Location()
+	switch lower {
+	case "":
+		return time.Time{}, errors.New("empty time")
+	case "刚刚", "刚才", "just now", "now":
+		return now, nil
+	}
+
+	if m := relativeTimeRegexp.FindStringSubmatch(lower); m != nil {
+		var n float64
+		switch m[1] {
+		case "半":
+			n = 0.5
+		case "a", "an", "one":
+			n = 1
+		default:
+			if v, err := strconv.Atoi(m[1]); err == nil {
+				n = float64(v)
+			} else if v, err := ChineseToNumber(m[1]); err == nil {
+				n = float64(v)
+			}
+		}
+		unit := strings.TrimPrefix(strings.TrimPrefix(m[2], "个"), "个")
+		switch {
+		case unit == "秒" || strings.HasPrefix(unit, "sec"):
+			return now.Add(-time.Duration(n * float64(time.Second))), nil
+		case unit == "分钟" || unit == "分" || strings.HasPrefix(unit, "min"):
+			return now.Add(-time.Duration(n * float64(time.Minute))), nil
+		case unit == "小时" || unit == "钟头" || strings.HasPrefix(unit, "h"):
+			return now.Add(-time.Duration(n * float64(time.Hour))), nil
+		case unit == "天" || unit == "日" || strings.HasPrefix(unit, "day"):
+			return now.AddDate(0, 0, -int(n)), nil
+		case unit == "周" || unit == "星期" || strings.HasPrefix(unit, "week"):
+			return now.AddDate(0, 0, -7*int(n)), nil
+		case unit == "月" || strings.HasPrefix(unit, "month"):
+			return now.AddDate(0, -int(n), 0), nil
+		default:
+			return now.AddDate(-int(n), 0, 0), nil
+		}
+	}
+
+	if m := dayOffsetRegexp.FindStringSubmatch(lower); m != nil {
+		days := map[string]int{"今天": 0, "今日": 0, "today": 0, "昨天": 1, "昨日": 1, "yesterday": 1, "前天": 2, "大前天": 3}[m[1]]
+		d := now.AddDate(0, 0, -days)
+		t := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+		if m[2] != "" {
+			clock := m[2]
+			if strings.Count(clock, ":") == 1 {
+				clock += ":00"
+			}
+			c, err := time.Parse("15:04:05", clock)
+			if err != nil {
+				return time.Time{}, err
+			}
+			t = t.Add(time.Duration(c.Hour())*time.Hour + time.Duration(c.Minute())*time.Minute + time.Duration(c.Second())*time.Second)
+		}
+		return t, nil
+	}
+
+	if strings.Trim(s, "0123456789") == "" && (len(s) == 10 || len(s) == 13) {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		if len(s) == 13 {
+			return time.Unix(n/1000, n%1000*int64(time.Millisecond)).In(loc), nil
+		}
+		return time.Unix(n, 0).In(loc), nil
+	}
+
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") {
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t, nil
+	}
+	return time.Time{}, errors.New("unknown time format: " + s)
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseTime(t *testing.T) {
+	Convey("测试时间文本解析", t, func() {
+		now := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+		cases := map[string]time.Time{
+			"刚刚":               now,
+			"3小时前":             now.Add(-3 * time.Hour),
+			"半小时前":             now.Add(-30 * time.Minute),
+			"五分钟前":             now.Add(-5 * time.Minute),
+			"2天前":              now.AddDate(0, 0, -2),
+			"1个月前":             now.AddDate(0, -1, 0),
+			"an hour ago":      now.Add(-time.Hour),
+			"5 minutes ago":    now.Add(-5 * time.Minute),
+			"昨天":               time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC),
+			"前天 08:05":         time.Date(2024, 3, 13, 8, 5, 0, 0, time.UTC),
+			"yesterday 12:30":  time.Date(2024, 3, 14, 12, 30, 0, 0, time.UTC),
+			"2023年5月6日":        time.Date(2023, 5, 6, 0, 0, 0, 0, time.UTC),
+			"2023-05-06 07:08": time.Date(2023, 5, 6, 7, 8, 0, 0, time.UTC),
+			"3月1日 09:00":       time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+			"12月1日":            time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+			"Jan 2, 2006":      time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC),
+			"1710498600":       time.Unix(1710498600, 0).UTC(),
+		}
+		for s, want := range cases {
+			got, err := ParseTime(s, now)
+			So(err, ShouldBeNil)
+			So(got.Equal(want), ShouldBeTrue)
+		}
+		_, err := ParseTime("不是时间", now)
+		So(err, ShouldNotBeNil)
+	})
+}
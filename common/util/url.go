@@ -70,3 +70,20 @@ func HTTPBuildQuery(values map[string]interface{}, encType int) string {
 	}
 	return ss
 }
+
+// QueryParam 返回URL中查询参数的值，也查找 # 之后的查询参数，如 /#/item?id=1，没有时返回空字符串
+func QueryParam(rawurl, name string) string {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil {
+		return ""
+	}
+	if v := u.Query().Get(name); v != "" {
+		return v
+	}
+	if p := strings.Index(u.Fragment, "?"); p >= 0 {
+		if q, err := url.ParseQuery(u.Fragment[p+1:]); err == nil {
+			return q.Get(name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,219 @@
+// Package control 提供可嵌入的HTTP接口，查看运行中任务的状态，控制任务
+//
+//	GET  /tasks                  全部任务的状态
+//	GET  /tasks/{id}             任务的状态：运行，队列长度，执行中的协程，每个规则的统计，错误率，代理
+//	GET  /tasks/{id}/errors      最近的错误，最新的在前
+//	POST /tasks/{id}/urls        添加URL，请求体为 {"urls": ["..."]}，按规则处理
+//	POST /tasks/{id}/pause       暂停，可以带 ?for=10m 暂停一段时间
+//	POST /tasks/{id}/resume      继续
+//	POST /tasks/{id}/stop        停止并保存队列
+//	POST /tasks/{id}/close       停止不保存队列
+//	POST /tasks/{id}/settings    修改设置，请求体为 {"interval": 500, "routine_num": 5}
+//	GET  /metrics                Prometheus 文本格式的指标，调用 SetMetrics 后可用
+//
+// 返回JSON，出错时返回 {"error": "..."} 和对应的状态码
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/safeie/spider/component/task"
+)
+
+// Server 任务的控制接口，实现 http.Handler，可以挂载到其他的路由下
+type Server struct {
+	tasks   map[string]*task.Task // 任务，键为任务编号
+	metrics http.Handler          // 指标输出，为 nil 时没有 /metrics
+	mu      sync.RWMutex
+}
+
+// settings 运行中可以修改的设置
+type settings struct {
+	Interval   *int `json:"interval"`    // 执行间隔，单位 毫秒
+	RoutineNum *int `json:"routine_num"` // 协程数量
+}
+
+// New 创建一个控制接口
+func New() *Server {
+	s := new(Server)
+	s.tasks = make(map[string]*task.Task)
+	return s
+}
+
+// Add 添加任务，任务编号相同的将被替换
+func (s *Server) Add(tasks ...*task.Task) *Server {
+	s.mu.Lock()
+	for _, t := range tasks {
+		s.tasks[t.ID()] = t
+	}
+	s.mu.Unlock()
+	return s
+}
+
+// SetMetrics 设置 /metrics 的输出，通常为任务共用的 metrics.Registry
+func (s *Server) SetMetrics(h http.Handler) *Server {
+	s.mu.Lock()
+	s.metrics = h
+	s.mu.Unlock()
+	return s
+}
+
+// Remove 移除任务
+func (s *Server) Remove(id string) {
+	s.mu.Lock()
+	delete(s.tasks, id)
+	s.mu.Unlock()
+}
+
+// ListenAndServe 在 addr 上提供控制接口，阻塞直到出错
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+// ServeHTTP 处理请求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "metrics" {
+		s.mu.RLock()
+		h := s.metrics
+		s.mu.RUnlock()
+		if h == nil {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.ServeHTTP(w, r)
+		return
+	}
+	if parts[0] != "tasks" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.stats())
+		return
+	}
+
+	s.mu.RLock()
+	t, ok := s.tasks[parts[1]]
+	s.mu.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("task %s not found", parts[1]))
+		return
+	}
+	action := ""
+	if len(parts) == 3 {
+		action = parts[2]
+	}
+
+	switch action {
+	case "", "errors":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if action == "" {
+			writeJSON(w, http.StatusOK, t.Stats())
+		} else {
+			writeJSON(w, http.StatusOK, t.RecentErrors())
+		}
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	switch action {
+	case "urls":
+		var body struct {
+			URLs []string `json:"urls"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		t.PushURL(body.URLs...)
+	case "pause":
+		if v := r.URL.Query().Get("for"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("bad duration %q", v))
+				return
+			}
+			t.PauseFor(d)
+		} else {
+			t.Pause()
+		}
+	case "resume":
+		t.Resume()
+	case "stop", "close":
+		if !t.IsRunning() {
+			writeError(w, http.StatusConflict, fmt.Sprintf("task %s is not running", t.ID()))
+			return
+		}
+		if action == "stop" {
+			go t.Stop()
+		} else {
+			go t.Close()
+		}
+	case "settings":
+		var v settings
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if (v.Interval != nil && *v.Interval <= 0) || (v.RoutineNum != nil && *v.RoutineNum <= 0) {
+			writeError(w, http.StatusBadRequest, "interval and routine_num should be positive")
+			return
+		}
+		if v.Interval != nil {
+			t.SetInterval(*v.Interval)
+		}
+		if v.RoutineNum != nil {
+			t.SetRoutineNum(*v.RoutineNum)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, t.Stats())
+}
+
+// stats 返回全部任务的状态，按任务编号排序
+func (s *Server) stats() []task.Stats {
+	s.mu.RLock()
+	list := make([]task.Stats, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		list = append(list, t.Stats())
+	}
+	s.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// writeJSON 输出JSON
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 输出错误
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,255 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+// bloomMagic 布隆过滤器文件的标识
+const bloomMagic = 0x53424631 // SBF1
+
+// bloomTightening 每个新的过滤器的误判率是上一个的倍数，总的误判率不超过设置的误判率
+const bloomTightening = 0.5
+
+// Bloom 可扩展的布隆过滤器，容量满后添加一个容量翻倍的过滤器，不会有漏判，按设置的误判率误判
+// 设置了文件时，打开时加载，Sync 和 Close 时保存
+type Bloom struct {
+	file     string         // 保存的文件，为空不保存
+	capacity int            // 第一个过滤器的容量
+	fpRate   float64        // 误判率
+	filters  []*bloomFilter // 过滤器，只向最后一个添加
+	count    int            // 添加的数量
+	rw       sync.RWMutex
+}
+
+// bloomFilter 固定容量的布隆过滤器
+type bloomFilter struct {
+	bits     []uint64 // 位数组
+	m        uint64   // 位数
+	k        uint64   // 散列函数的数量
+	capacity int      // 容量
+	count    int      // 已添加的数量
+}
+
+// NewBloom 创建一个可扩展的布隆过滤器，capacity 为预计的数量，fpRate 为误判率，如 0.001
+func NewBloom(capacity int, fpRate float64) *Bloom {
+	if capacity <= 0 {
+		capacity = 100000
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+	t := new(Bloom)
+	t.capacity = capacity
+	t.fpRate = fpRate
+	return t
+}
+
+// OpenBloom 打开保存在文件中的布隆过滤器，文件不存在时创建新的过滤器
+// 加载的过滤器使用文件中保存的容量和误判率
+func OpenBloom(file string, capacity int, fpRate float64) (*Bloom, error) {
+	t := NewBloom(capacity, fpRate)
+	t.file = file
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err = t.load(bufio.NewReader(f)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// newBloomFilter 按容量和误判率创建一个过滤器
+func newBloomFilter(capacity int, fpRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	k := uint64(math.Ceil(math.Ln2 * float64(m) / float64(capacity)))
+	if k < 1 {
+		k = 1
+	}
+	f := new(bloomFilter)
+	f.bits = make([]uint64, m/64)
+	f.m = m
+	f.k = k
+	f.capacity = capacity
+	return f
+}
+
+// has 判断摘要是否在过滤器中
+func (f *bloomFilter) has(h1, h2 uint64) bool {
+	for i := uint64(0); i < f.k; i++ {
+		pos := (h1 + i*h2) % f.m
+		if f.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add 添加摘要到过滤器
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < f.k; i++ {
+		pos := (h1 + i*h2) % f.m
+		f.bits[pos/64] |= 1 << (pos % 64)
+	}
+	f.count++
+}
+
+// Add 添加一个键，已经存在(或误判为存在)时返回 true
+func (t *Bloom) Add(key string) bool {
+	h1, h2 := hash(key)
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if t.has(h1, h2) {
+		return true
+	}
+	last := len(t.filters) - 1
+	if last < 0 || t.filters[last].count >= t.filters[last].capacity {
+		// 第 n 个过滤器的容量为 capacity*2^n，误判率为 fpRate*(1-r)*r^n，总和不超过 fpRate
+		n := len(t.filters)
+		rate := t.fpRate * (1 - bloomTightening) * math.Pow(bloomTightening, float64(n))
+		t.filters = append(t.filters, newBloomFilter(t.capacity<<uint(n), rate))
+		last++
+	}
+	t.filters[last].add(h1, h2)
+	t.count++
+	return false
+}
+
+// has 判断摘要是否在任一过滤器中
+func (t *Bloom) has(h1, h2 uint64) bool {
+	for _, f := range t.filters {
+		if f.has(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// Has 判断键是否存在，可能误判为存在
+func (t *Bloom) Has(key string) bool {
+	h1, h2 := hash(key)
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	return t.has(h1, h2)
+}
+
+// Len 返回添加的数量
+func (t *Bloom) Len() int {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	return t.count
+}
+
+// Reset 清空过滤器
+func (t *Bloom) Reset() error {
+	t.rw.Lock()
+	t.filters = nil
+	t.count = 0
+	t.rw.Unlock()
+	return nil
+}
+
+// Sync 保存到文件，没有设置文件时不处理
+func (t *Bloom) Sync() error {
+	if t.file == "" {
+		return nil
+	}
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	tmp := t.file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err = t.save(w); err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, t.file)
+}
+
+// Close 保存到文件
+func (t *Bloom) Close() error {
+	return t.Sync()
+}
+
+// Persistent 设置了文件时保存到文件，在多次运行之间保留
+func (t *Bloom) Persistent() bool {
+	return t.file != ""
+}
+
+// save 写入过滤器，格式为：标识，容量，误判率，过滤器数量，每个过滤器的 位数，散列数，容量，数量，位数组
+func (t *Bloom) save(w io.Writer) error {
+	head := []interface{}{uint32(bloomMagic), uint64(t.capacity), t.fpRate, uint32(len(t.filters))}
+	for _, v := range head {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	for _, f := range t.filters {
+		for _, v := range []interface{}{f.m, f.k, uint64(f.capacity), uint64(f.count), f.bits} {
+			if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// load 读取过滤器，格式同 save
+func (t *Bloom) load(r io.Reader) error {
+	var magic, n uint32
+	var capacity uint64
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return err
+	}
+	if magic != bloomMagic {
+		return errors.New("dedup: not a bloom filter file")
+	}
+	for _, v := range []interface{}{&capacity, &t.fpRate, &n} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	t.capacity = int(capacity)
+	t.filters = make([]*bloomFilter, 0, n)
+	t.count = 0
+	for i := uint32(0); i < n; i++ {
+		f := new(bloomFilter)
+		var fcap, fcount uint64
+		for _, v := range []interface{}{&f.m, &f.k, &fcap, &fcount} {
+			if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+				return err
+			}
+		}
+		if f.m == 0 || f.m%64 != 0 {
+			return errors.New("dedup: broken bloom filter file")
+		}
+		f.capacity, f.count = int(fcap), int(fcount)
+		f.bits = make([]uint64, f.m/64)
+		if err := binary.Read(r, binary.LittleEndian, f.bits); err != nil {
+			return err
+		}
+		t.filters = append(t.filters, f)
+		t.count += f.count
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package dedup 提供判断重复的存储，用于记录抓取过的URL
+//
+// 内存存储(NewMap)精确但随URL数量增长，布隆过滤器(NewBloom)按误判率使用固定比例的内存，
+// 磁盘存储(OpenDisk)只在磁盘上保存摘要，内存占用不随数量增长，布隆过滤器和磁盘存储可以在多次运行之间保留
+package dedup
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// Store 判断重复的存储
+type Store interface {
+	// Add 添加一个键，已经存在时返回 true
+	Add(key string) bool
+	// Has 判断键是否存在
+	Has(key string) bool
+	// Len 返回键的数量，布隆过滤器为添加的次数
+	Len() int
+	// Reset 清空存储
+	Reset() error
+	// Sync 将内容保存到磁盘，内存存储不处理
+	Sync() error
+	// Close 保存并关闭存储
+	Close() error
+}
+
+// Map 内存存储，精确判断，不保存到磁盘
+type Map struct {
+	keys map[string]struct{}
+	rw   sync.RWMutex
+}
+
+// NewMap 创建一个内存存储
+func NewMap() *Map {
+	t := new(Map)
+	t.keys = make(map[string]struct{})
+	return t
+}
+
+// Add 添加一个键，已经存在时返回 true
+func (t *Map) Add(key string) bool {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if _, ok := t.keys[key]; ok {
+		return true
+	}
+	t.keys[key] = struct{}{}
+	return false
+}
+
+// Has 判断键是否存在
+func (t *Map) Has(key string) bool {
+	t.rw.RLock()
+	_, ok := t.keys[key]
+	t.rw.RUnlock()
+	return ok
+}
+
+// Len 返回键的数量
+func (t *Map) Len() int {
+	t.rw.RLock()
+	n := len(t.keys)
+	t.rw.RUnlock()
+	return n
+}
+
+// Reset 清空存储
+func (t *Map) Reset() error {
+	t.rw.Lock()
+	t.keys = make(map[string]struct{})
+	t.rw.Unlock()
+	return nil
+}
+
+// Sync 内存存储不处理
+func (t *Map) Sync() error {
+	return nil
+}
+
+// Close 内存存储不处理
+func (t *Map) Close() error {
+	return nil
+}
+
+// Persister 存储可以实现的接口，Persistent 返回 true 时存储在多次运行之间保留，任务重置时不清空
+type Persister interface {
+	Persistent() bool
+}
+
+// Persistent 判断存储是否在多次运行之间保留，存储实现了 Persister 时按其返回值，否则为 false
+func Persistent(s Store) bool {
+	if p, ok := s.(Persister); ok {
+		return p.Persistent()
+	}
+	return false
+}
+
+// hash 返回键的两个64位摘要，用于布隆过滤器的双重散列和磁盘存储
+func hash(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+	return h1.Sum64(), h2.Sum64() | 1
+}
@@ -0,0 +1,156 @@
+package dedup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMap(t *testing.T) {
+	Convey("测试内存存储", t, func() {
+		s := NewMap()
+		So(s.Add("a"), ShouldBeFalse)
+		So(s.Add("a"), ShouldBeTrue)
+		So(s.Has("a"), ShouldBeTrue)
+		So(s.Len(), ShouldEqual, 1)
+		So(Persistent(s), ShouldBeFalse)
+		So(s.Reset(), ShouldBeNil)
+		So(s.Has("a"), ShouldBeFalse)
+	})
+
+	Convey("测试自定义存储的保留", t, func() {
+		So(Persistent(&persistentMap{Map: NewMap(), persistent: true}), ShouldBeTrue)
+		So(Persistent(&persistentMap{Map: NewMap()}), ShouldBeFalse)
+	})
+}
+
+// persistentMap 实现了 Persister 的自定义存储
+type persistentMap struct {
+	*Map
+	persistent bool
+}
+
+func (t *persistentMap) Persistent() bool {
+	return t.persistent
+}
+
+func TestBloom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("测试布隆过滤器的扩容、保存和加载", t, func() {
+		file := filepath.Join(dir, "urls.bloom")
+		s, err := OpenBloom(file, 100, 0.001)
+		So(err, ShouldBeNil)
+		So(Persistent(s), ShouldBeTrue)
+		So(Persistent(NewBloom(100, 0.001)), ShouldBeFalse)
+		dups := 0
+		for i := 0; i < 1000; i++ {
+			if s.Add("https://example.com/" + strconv.Itoa(i)) {
+				dups++ // 误判
+			}
+		}
+		So(dups, ShouldBeLessThan, 10)
+		So(len(s.filters), ShouldBeGreaterThan, 1)
+		So(s.Add("https://example.com/1"), ShouldBeTrue)
+		So(s.Close(), ShouldBeNil)
+
+		n, err := OpenBloom(file, 0, 0)
+		So(err, ShouldBeNil)
+		So(n.Len(), ShouldEqual, s.Len())
+		So(n.capacity, ShouldEqual, 100)
+		So(len(n.filters), ShouldEqual, len(s.filters))
+		found := 0
+		for i := 0; i < 1000; i++ {
+			if n.Has("https://example.com/" + strconv.Itoa(i)) {
+				found++
+			}
+		}
+		So(found, ShouldEqual, 1000)
+		misses := 0
+		for i := 1000; i < 2000; i++ {
+			if !n.Has("https://example.com/" + strconv.Itoa(i)) {
+				misses++
+			}
+		}
+		So(misses, ShouldBeGreaterThan, 990)
+
+		So(n.Reset(), ShouldBeNil)
+		So(n.Len(), ShouldEqual, 0)
+		So(n.Has("https://example.com/1"), ShouldBeFalse)
+
+		bad := filepath.Join(dir, "bad.bloom")
+		So(ioutil.WriteFile(bad, []byte("not a bloom filter"), 0644), ShouldBeNil)
+		_, err = OpenBloom(bad, 100, 0.001)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("测试磁盘存储的扩容、保存和加载", t, func() {
+		file := filepath.Join(dir, "urls.disk")
+		s, err := OpenDisk(file, 10)
+		So(err, ShouldBeNil)
+		So(Persistent(s), ShouldBeTrue)
+		So(s.slots, ShouldEqual, 1024)
+		dups := 0
+		for i := 0; i < 3000; i++ {
+			if s.Add("https://example.com/" + strconv.Itoa(i)) {
+				dups++
+			}
+		}
+		So(dups, ShouldEqual, 0)
+		So(s.slots, ShouldEqual, 8192)
+		So(s.Add("https://example.com/1"), ShouldBeTrue)
+		So(s.Len(), ShouldEqual, 3000)
+		So(s.Close(), ShouldBeNil)
+		_, err = os.Stat(file + ".tmp")
+		So(os.IsNotExist(err), ShouldBeTrue)
+
+		n, err := OpenDisk(file, 10)
+		So(err, ShouldBeNil)
+		So(n.Len(), ShouldEqual, 3000)
+		So(n.slots, ShouldEqual, 8192)
+		found := 0
+		for i := 0; i < 3000; i++ {
+			if n.Has("https://example.com/" + strconv.Itoa(i)) {
+				found++
+			}
+		}
+		So(found, ShouldEqual, 3000)
+		So(n.Has("https://example.com/3000"), ShouldBeFalse)
+		So(n.Reset(), ShouldBeNil)
+		So(n.Len(), ShouldEqual, 0)
+		So(n.Has("https://example.com/1"), ShouldBeFalse)
+		So(n.Close(), ShouldBeNil)
+
+		bad := filepath.Join(dir, "bad.disk")
+		So(ioutil.WriteFile(bad, make([]byte, diskHeaderSize), 0644), ShouldBeNil)
+		_, err = OpenDisk(bad, 10)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("测试磁盘存储的读写错误", t, func() {
+		s, err := OpenDisk(filepath.Join(dir, "broken.disk"), 10)
+		So(err, ShouldBeNil)
+		So(s.Err(), ShouldBeNil)
+		s.file.Close()
+		So(s.Add("https://example.com/1"), ShouldBeFalse)
+		So(s.Err(), ShouldNotBeNil)
+		So(s.Sync(), ShouldNotBeNil)
+		So(s.Close(), ShouldNotBeNil)
+	})
+}
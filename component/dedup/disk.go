@@ -0,0 +1,297 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/safeie/spider/common/log"
+)
+
+const (
+	diskMagic      = 0x53445331 // SDS1
+	diskHeaderSize = 24         // 标识(4) 保留(4) 数量(8) 槽数(8)
+	diskSlotSize   = 8          // 每个槽保存一个64位摘要，0 为空
+	diskProbeSlots = 8          // 每次读取的槽数
+)
+
+// Disk 磁盘存储，以开放寻址的散列表保存键的64位摘要，内存占用不随数量增长
+// 摘要冲突的概率很低，百万数量级时约为 1e-8，负载超过一半时扩容为两倍
+type Disk struct {
+	name  string // 文件名
+	file  *os.File
+	count uint64 // 键的数量
+	slots uint64 // 槽数，2的幂
+	err   error  // 第一次读写错误，出错后判断重复不可靠，Sync 和 Close 时返回
+	mu    sync.Mutex
+}
+
+// OpenDisk 打开磁盘存储，文件不存在时按预计的数量 capacity 创建
+func OpenDisk(file string, capacity int) (*Disk, error) {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	t := new(Disk)
+	t.name = file
+	t.file = f
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size() == 0 {
+		if err = t.init(diskSlots(capacity)); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return t, nil
+	}
+	head := make([]byte, diskHeaderSize)
+	if _, err = f.ReadAt(head, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(head) != diskMagic {
+		f.Close()
+		return nil, errors.New("dedup: not a disk store file")
+	}
+	t.count = binary.LittleEndian.Uint64(head[8:])
+	t.slots = binary.LittleEndian.Uint64(head[16:])
+	if t.slots == 0 || t.slots&(t.slots-1) != 0 || info.Size() < int64(diskHeaderSize+t.slots*diskSlotSize) {
+		f.Close()
+		return nil, errors.New("dedup: broken disk store file")
+	}
+	return t, nil
+}
+
+// diskSlots 按预计的数量返回槽数，负载不超过一半
+func diskSlots(capacity int) uint64 {
+	slots := uint64(1024)
+	for slots < uint64(capacity)*2 {
+		slots <<= 1
+	}
+	return slots
+}
+
+// init 初始化为空的散列表
+func (t *Disk) init(slots uint64) error {
+	if err := t.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := t.file.Truncate(int64(diskHeaderSize + slots*diskSlotSize)); err != nil {
+		return err
+	}
+	t.count = 0
+	t.slots = slots
+	return t.writeHeader()
+}
+
+// writeHeader 写入文件头
+func (t *Disk) writeHeader() error {
+	head := make([]byte, diskHeaderSize)
+	binary.LittleEndian.PutUint32(head, diskMagic)
+	binary.LittleEndian.PutUint64(head[8:], t.count)
+	binary.LittleEndian.PutUint64(head[16:], t.slots)
+	_, err := t.file.WriteAt(head, 0)
+	return err
+}
+
+// fingerprint 返回键的摘要，0 表示空槽，不使用
+func fingerprint(key string) uint64 {
+	h, _ := hash(key)
+	if h == 0 {
+		h = 1
+	}
+	return h
+}
+
+// find 查找摘要，返回是否存在和所在或可插入的槽
+func (t *Disk) find(file *os.File, slots, fp uint64) (bool, uint64, error) {
+	buf := make([]byte, diskProbeSlots*diskSlotSize)
+	pos := fp & (slots - 1)
+	for probed := uint64(0); probed < slots; {
+		n := uint64(diskProbeSlots)
+		if pos+n > slots {
+			n = slots - pos
+		}
+		if _, err := file.ReadAt(buf[:n*diskSlotSize], int64(diskHeaderSize+pos*diskSlotSize)); err != nil {
+			return false, 0, err
+		}
+		for i := uint64(0); i < n; i++ {
+			v := binary.LittleEndian.Uint64(buf[i*diskSlotSize:])
+			if v == fp {
+				return true, pos + i, nil
+			}
+			if v == 0 {
+				return false, pos + i, nil
+			}
+		}
+		probed += n
+		pos = (pos + n) & (slots - 1)
+	}
+	return false, 0, errors.New("dedup: disk store is full")
+}
+
+// put 写入摘要到槽
+func put(file *os.File, slot, fp uint64) error {
+	buf := make([]byte, diskSlotSize)
+	binary.LittleEndian.PutUint64(buf, fp)
+	_, err := file.WriteAt(buf, int64(diskHeaderSize+slot*diskSlotSize))
+	return err
+}
+
+// fail 记录读写错误，只记录第一次错误
+func (t *Disk) fail(err error) {
+	if t.err == nil {
+		t.err = err
+		log.Errorf("dedup: disk store %s error: %v\n", t.name, err)
+	}
+}
+
+// Err 返回第一次读写错误，没有出错时为 nil
+func (t *Disk) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Add 添加一个键，已经存在时返回 true，磁盘出错时返回 false，错误由 Err、Sync 和 Close 返回
+func (t *Disk) Add(key string) bool {
+	fp := fingerprint(key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ok, slot, err := t.find(t.file, t.slots, fp)
+	if err != nil {
+		t.fail(err)
+		return false
+	}
+	if ok {
+		return true
+	}
+	if (t.count+1)*2 > t.slots {
+		if err = t.grow(); err != nil {
+			t.fail(err)
+			return false
+		}
+		if _, slot, err = t.find(t.file, t.slots, fp); err != nil {
+			t.fail(err)
+			return false
+		}
+	}
+	if err = put(t.file, slot, fp); err != nil {
+		t.fail(err)
+		return false
+	}
+	t.count++
+	return false
+}
+
+// grow 扩容为两倍，写入临时文件后替换
+func (t *Disk) grow() error {
+	tmp, err := os.OpenFile(t.name+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	n := &Disk{file: tmp}
+	if err = n.init(t.slots * 2); err == nil {
+		err = t.copyTo(n)
+	}
+	if err == nil {
+		err = n.writeHeader()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), t.name); err != nil {
+		tmp.Close()
+		return err
+	}
+	t.file.Close()
+	t.file = n.file
+	t.slots = n.slots
+	return nil
+}
+
+// copyTo 将全部摘要写入另一个散列表
+func (t *Disk) copyTo(n *Disk) error {
+	r := bufio.NewReaderSize(io.NewSectionReader(t.file, diskHeaderSize, int64(t.slots*diskSlotSize)), 1<<16)
+	buf := make([]byte, diskSlotSize)
+	for i := uint64(0); i < t.slots; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+		fp := binary.LittleEndian.Uint64(buf)
+		if fp == 0 {
+			continue
+		}
+		_, slot, err := n.find(n.file, n.slots, fp)
+		if err != nil {
+			return err
+		}
+		if err = put(n.file, slot, fp); err != nil {
+			return err
+		}
+		n.count++
+	}
+	return nil
+}
+
+// Has 判断键是否存在
+func (t *Disk) Has(key string) bool {
+	fp := fingerprint(key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ok, _, err := t.find(t.file, t.slots, fp)
+	if err != nil {
+		t.fail(err)
+	}
+	return ok
+}
+
+// Len 返回键的数量
+func (t *Disk) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int(t.count)
+}
+
+// Reset 清空存储，保留当前的槽数，清除之前的读写错误
+func (t *Disk) Reset() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = nil
+	return t.init(t.slots)
+}
+
+// Sync 写入数量并同步到磁盘，之前有读写错误时返回该错误
+func (t *Disk) Sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.writeHeader(); err != nil {
+		return err
+	}
+	if err := t.file.Sync(); err != nil {
+		return err
+	}
+	return t.err
+}
+
+// Close 保存并关闭文件
+func (t *Disk) Close() error {
+	if err := t.Sync(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
+
+// Persistent 磁盘存储总是保存到文件，在多次运行之间保留
+func (t *Disk) Persistent() bool {
+	return true
+}
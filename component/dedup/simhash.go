@@ -0,0 +1,106 @@
+package dedup
+
+import (
+	"sync"
+
+	"github.com/safeie/spider/common/util"
+)
+
+// SimIndex SimHash 指纹索引，查找海明距离不超过 distance 的指纹，用于判断内容近似重复
+// 将64位分为 distance+1 段，按抽屉原理，距离不超过 distance 的两个指纹至少有一段相同，按段建立索引
+type SimIndex struct {
+	distance int
+	bands    []simBand
+	tables   []map[uint64][]uint64
+	count    int
+	rw       sync.RWMutex
+}
+
+// simBand 指纹的一段
+type simBand struct {
+	shift uint
+	mask  uint64
+}
+
+// NewSimIndex 创建一个指纹索引，distance 为认为近似的最大海明距离，一般为 3
+func NewSimIndex(distance int) *SimIndex {
+	if distance < 0 {
+		distance = 0
+	}
+	if distance > 63 {
+		distance = 63
+	}
+	x := new(SimIndex)
+	x.distance = distance
+	n := distance + 1
+	width := 64 / n
+	for i := 0; i < n; i++ {
+		w := width
+		if i == n-1 {
+			w = 64 - width*(n-1) // 最后一段包括剩余的位
+		}
+		mask := ^uint64(0)
+		if w < 64 {
+			mask = 1<<uint(w) - 1
+		}
+		x.bands = append(x.bands, simBand{shift: uint(i * width), mask: mask})
+		x.tables = append(x.tables, make(map[uint64][]uint64))
+	}
+	return x
+}
+
+// Distance 返回认为近似的最大海明距离
+func (x *SimIndex) Distance() int {
+	return x.distance
+}
+
+// Near 查找与指纹近似的指纹，返回找到的指纹和是否找到
+func (x *SimIndex) Near(fp uint64) (uint64, bool) {
+	x.rw.RLock()
+	defer x.rw.RUnlock()
+	return x.near(fp)
+}
+
+// near 查找近似的指纹，不加锁
+func (x *SimIndex) near(fp uint64) (uint64, bool) {
+	for i, b := range x.bands {
+		for _, v := range x.tables[i][fp>>b.shift&b.mask] {
+			if util.HammingDistance(fp, v) <= x.distance {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Add 添加一个指纹，已有近似的指纹时不添加，返回 true
+func (x *SimIndex) Add(fp uint64) bool {
+	x.rw.Lock()
+	defer x.rw.Unlock()
+	if _, ok := x.near(fp); ok {
+		return true
+	}
+	for i, b := range x.bands {
+		key := fp >> b.shift & b.mask
+		x.tables[i][key] = append(x.tables[i][key], fp)
+	}
+	x.count++
+	return false
+}
+
+// Len 返回指纹的数量
+func (x *SimIndex) Len() int {
+	x.rw.RLock()
+	defer x.rw.RUnlock()
+	return x.count
+}
+
+// Reset 清空索引
+func (x *SimIndex) Reset() {
+	x.rw.Lock()
+	for i := range x.tables {
+		x.tables[i] = make(map[uint64][]uint64)
+	}
+	x.count = 0
+	x.rw.Unlock()
+}
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"math/rand"
 	"net/http"
 	"net/url"
@@ -44,6 +45,9 @@ type Option struct {
 	proxyAddr     string            // 代理服务器地址
 	renderDelay   int               // 渲染等待，单位 毫秒，用于js渲染时获取内容前的等待，确保渲染完成
 	timeout       int               // 抓取超时，单位 秒
+	transport     http.RoundTripper // 共用的HTTP传输，为空时每次请求创建
+	proxyLock     sync.RWMutex      // 使用的代理锁
+	proxyUsed     string            // 最近一次使用的代理
 }
 
 // NewOption 创新新的抓取配置
@@ -166,6 +170,46 @@ func (t *Option) SetTimeout(v int) {
 	t.timeout = v
 }
 
+// SetTransport 设置共用的HTTP传输，用于多个任务共用连接和代理，设置了代理地址的请求仍使用独立的传输
+func (t *Option) SetTransport(rt http.RoundTripper) {
+	t.transport = rt
+}
+
+// GetTransport 获取共用的HTTP传输
+func (t *Option) GetTransport() http.RoundTripper {
+	return t.transport
+}
+
+// GetUsedProxy 获取最近一次请求使用的代理地址，没有使用代理时为空
+func (t *Option) GetUsedProxy() string {
+	t.proxyLock.RLock()
+	defer t.proxyLock.RUnlock()
+	return t.proxyUsed
+}
+
+// setUsedProxy 记录请求使用的代理地址
+func (t *Option) setUsedProxy(v string) {
+	t.proxyLock.Lock()
+	t.proxyUsed = v
+	t.proxyLock.Unlock()
+}
+
+// usedProxyKey 请求上下文中记录代理地址的回调的键
+type usedProxyKey struct{}
+
+// withUsedProxy 在请求上下文中附加记录代理地址的回调，共用的HTTP传输选择代理后通过 RecordProxy 回调
+func withUsedProxy(req *http.Request, fn func(string)) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), usedProxyKey{}, fn))
+}
+
+// RecordProxy 记录请求实际使用的代理地址，由共用的HTTP传输的 Proxy 函数在选择代理后调用，
+// 任务的 Option.GetUsedProxy 返回该地址
+func RecordProxy(req *http.Request, addr string) {
+	if fn, ok := req.Context().Value(usedProxyKey{}).(func(string)); ok {
+		fn(addr)
+	}
+}
+
 // GetProxyAddr 获取代理IP地址
 func GetProxyAddr(option *Option) string {
 	// 不使用代理
@@ -287,47 +331,33 @@ func GetCharsetFromHTML(body []byte) string {
 	return strings.TrimSpace(strings.ToUpper(charset))
 }
 
-// FixURL 修复相对路径
+// FixURL 修复相对路径，按 RFC 3986 相对于 base 解析，去掉锚点
+// 锚点，javascript:, mailto:, tel:, data: 链接返回空字符串
 func FixURL(base, href string) string {
-	if href == "" {
+	href = strings.TrimSpace(href)
+	if href == "" || href[0] == '#' {
 		return ""
 	}
-
-	if href[0] == '.' && len(href) > 1 && href[1] == '/' {
-		href = href[2:]
+	// 过滤非网页链接
+	lower := strings.ToLower(href)
+	for _, scheme := range []string{"javascript:", "mailto:", "tel:", "data:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return ""
+		}
 	}
 
-	// 过滤锚点
-	if href[0] == '#' {
-		return ""
-	}
-	if pos := strings.Index(href, "#"); pos > 0 {
-		href = href[0:pos]
-	}
-	// 过滤JS连接
-	if strings.Contains(href, "javascript:") {
-		return ""
-	}
-	// 过滤mailto
-	if strings.Contains(href, "mailto:") {
+	ref, err := url.Parse(href)
+	if err != nil {
 		return ""
 	}
-	// 完整的URL
-	if strings.Contains(href, "://") {
-		return href
-	}
-
 	u, err := url.Parse(base)
 	if err != nil {
 		return ""
 	}
-	// 补齐URL
-	if href[0] == '/' {
-		if len(href) > 1 && href[1] == '/' {
-			// 补齐协议，现在流行这种URL  //u.domain.com
-			return u.Scheme + ":" + href
-		}
-		return u.Scheme + "://" + u.Host + href
+	u = u.ResolveReference(ref)
+	u.Fragment, u.RawFragment = "", ""
+	if u.Scheme == "" || u.Host == "" {
+		return ""
 	}
-	return u.Scheme + "://" + u.Host + u.Path + "/" + href
+	return u.String()
 }
@@ -0,0 +1,38 @@
+package fetcher
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFixURL(t *testing.T) {
+	Convey("测试修复相对路径", t, func() {
+		base := "http://example.com/a/b/c.html?p=1"
+		cases := map[string]string{
+			"../d.html":                     "http://example.com/a/d.html",
+			"../../d.html":                  "http://example.com/d.html",
+			"./d.html":                      "http://example.com/a/b/d.html",
+			"d.html#top":                    "http://example.com/a/b/d.html",
+			"/d.html":                       "http://example.com/d.html",
+			"?x":                            "http://example.com/a/b/c.html?x",
+			"?x=2":                          "http://example.com/a/b/c.html?x=2",
+			"//cdn.example.com/i.png":       "http://cdn.example.com/i.png",
+			"https://example.org/e":         "https://example.org/e",
+			"  /d.html  ":                   "http://example.com/d.html",
+			"https://example.org/e#section": "https://example.org/e",
+		}
+		for href, want := range cases {
+			So(FixURL(base, href), ShouldEqual, want)
+		}
+		So(FixURL("https://example.com/a/", "//cdn.example.com/i.png"), ShouldEqual, "https://cdn.example.com/i.png")
+	})
+
+	Convey("测试过滤非网页链接", t, func() {
+		base := "http://example.com/a/"
+		for _, href := range []string{"", "#", "#top", "javascript:void(0)", "JavaScript:go()", "mailto:a@example.com", "tel:123", "data:image/png;base64,AA"} {
+			So(FixURL(base, href), ShouldEqual, "")
+		}
+		So(FixURL("", "d.html"), ShouldEqual, "")
+	})
+}
@@ -74,13 +74,20 @@ func (t *Gokit) Fetch(url string, params, headers map[string]string) (*Response,
 	if t.option.timeout > 0 {
 		client.Timeout = time.Second * time.Duration(t.option.timeout)
 	}
-	client.Transport = &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+	client.Transport = t.option.transport
+	if client.Transport != nil {
+		req = withUsedProxy(req, t.option.setUsedProxy)
+	} else {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		}
 	}
 
-	if proxyAddr := GetProxyAddr(t.option); proxyAddr != "" {
+	proxyAddr := GetProxyAddr(t.option)
+	t.option.setUsedProxy(proxyAddr)
+	if proxyAddr != "" {
 		if proxy, err := neturl.Parse(proxyAddr); err == nil {
 			client.Transport = &http.Transport{
 				Proxy: http.ProxyURL(proxy),
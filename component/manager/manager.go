@@ -0,0 +1,340 @@
+// Package manager 在一个进程中管理多个任务，多个任务共用并发配额、HTTP连接池和代理
+package manager
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/safeie/spider/component/fetcher"
+	"github.com/safeie/spider/component/task"
+)
+
+// Status 任务的状态
+type Status struct {
+	ID        string    `json:"id"`         // 任务编号
+	Name      string    `json:"name"`       // 任务名称
+	Running   bool      `json:"running"`    // 是否在执行中
+	Paused    bool      `json:"paused"`     // 是否暂停
+	Active    int       `json:"active"`     // 占用的并发配额
+	LastStart time.Time `json:"last_start"` // 最近一次开始的时间
+	LastEnd   time.Time `json:"last_end"`   // 最近一次结束的时间
+	LastError string    `json:"last_error"` // 最近一次执行的错误，成功时为空
+}
+
+// entry 一个管理的任务
+type entry struct {
+	task    *task.Task
+	limiter *limiter
+	status  Status
+}
+
+// Manager 任务管理器，所有任务的抓取协程总数不超过并发配额，按任务编号启动、停止、暂停任务
+type Manager struct {
+	entries   map[string]*entry // 任务，键为任务编号
+	slots     chan struct{}     // 并发配额
+	transport *http.Transport   // 共用的HTTP传输
+	proxies   []*neturl.URL     // 共用的代理，轮流使用
+	next      uint32            // 下一个代理的序号
+	wg        sync.WaitGroup    // 执行中的任务
+	mu        sync.RWMutex
+}
+
+// New 创建一个任务管理器，concurrency 为所有任务共用的抓取协程数
+func New(concurrency int) *Manager {
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	m := new(Manager)
+	m.entries = make(map[string]*entry)
+	m.slots = make(chan struct{}, concurrency)
+	m.transport = &http.Transport{
+		Proxy: m.proxy,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+		MaxIdleConns:        concurrency * 4,
+		MaxIdleConnsPerHost: concurrency,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return m
+}
+
+// SetProxies 设置共用的代理地址，任务的请求轮流使用，任务自己设置了代理时使用任务的代理
+func (m *Manager) SetProxies(addrs ...string) error {
+	proxies := make([]*neturl.URL, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "://") {
+			addr = "http://" + addr
+		}
+		u, err := neturl.Parse(addr)
+		if err != nil {
+			return fmt.Errorf("manager: bad proxy %q: %v", addr, err)
+		}
+		proxies = append(proxies, u)
+	}
+	m.mu.Lock()
+	m.proxies = proxies
+	m.mu.Unlock()
+	m.transport.CloseIdleConnections()
+	return nil
+}
+
+// Proxies 返回共用的代理地址
+func (m *Manager) Proxies() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	addrs := make([]string, 0, len(m.proxies))
+	for _, u := range m.proxies {
+		addrs = append(addrs, u.String())
+	}
+	return addrs
+}
+
+// proxy 为请求选择一个代理，没有设置代理时直接连接，选择的代理记录到任务的抓取设置中
+func (m *Manager) proxy(req *http.Request) (*neturl.URL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.proxies) == 0 {
+		return nil, nil
+	}
+	i := atomic.AddUint32(&m.next, 1)
+	p := m.proxies[int(i)%len(m.proxies)]
+	fetcher.RecordProxy(req, p.String())
+	return p, nil
+}
+
+// Transport 返回共用的HTTP传输
+func (m *Manager) Transport() *http.Transport {
+	return m.transport
+}
+
+// Concurrency 返回并发配额和已占用的数量
+func (m *Manager) Concurrency() (int, int) {
+	return cap(m.slots), len(m.slots)
+}
+
+// Add 添加一个任务，任务编号不能重复，任务使用共用的并发配额和HTTP传输
+func (m *Manager) Add(t *task.Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[t.ID()]; ok {
+		return fmt.Errorf("manager: task %s already added", t.ID())
+	}
+	e := &entry{task: t, limiter: &limiter{slots: m.slots}}
+	e.status.ID = t.ID()
+	e.status.Name = t.Name()
+	t.SetLimiter(e.limiter).SetTransport(m.transport)
+	m.entries[t.ID()] = e
+	return nil
+}
+
+// Remove 移除一个任务，执行中的任务不能移除
+func (m *Manager) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[id]
+	if !ok {
+		return fmt.Errorf("manager: task %s not found", id)
+	}
+	if e.status.Running {
+		return fmt.Errorf("manager: task %s is running", id)
+	}
+	delete(m.entries, id)
+	return nil
+}
+
+// Task 返回任务
+func (m *Manager) Task(id string) (*task.Task, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[id]
+	if !ok {
+		return nil, false
+	}
+	return e.task, true
+}
+
+// get 返回任务，不存在时返回错误
+func (m *Manager) get(id string) (*entry, error) {
+	e, ok := m.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("manager: task %s not found", id)
+	}
+	return e, nil
+}
+
+// Start 开始执行一个任务，不阻塞，任务在执行中时返回错误
+func (m *Manager) Start(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	if e.status.Running {
+		return fmt.Errorf("manager: task %s is running", id)
+	}
+	e.status.Running = true
+	e.status.LastStart = time.Now()
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		err := e.task.Run()
+		m.mu.Lock()
+		e.status.Running = false
+		e.status.LastEnd = time.Now()
+		e.status.LastError = ""
+		if err != nil {
+			e.status.LastError = err.Error()
+		}
+		m.mu.Unlock()
+	}()
+	return nil
+}
+
+// StartAll 开始执行全部没有执行的任务
+func (m *Manager) StartAll() {
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.entries))
+	for id, e := range m.entries {
+		if !e.status.Running {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.RUnlock()
+	for _, id := range ids {
+		m.Start(id)
+	}
+}
+
+// Stop 停止一个任务并保存队列，不等待任务结束
+func (m *Manager) Stop(id string) error {
+	m.mu.RLock()
+	e, err := m.get(id)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	go e.task.Stop()
+	return nil
+}
+
+// Close 关闭一个任务，不保存队列，不等待任务结束
+func (m *Manager) Close(id string) error {
+	m.mu.RLock()
+	e, err := m.get(id)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	go e.task.Close()
+	return nil
+}
+
+// StopAll 停止全部任务并保存队列，等待任务结束
+func (m *Manager) StopAll() {
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.entries))
+	for id, e := range m.entries {
+		if e.status.Running {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.RUnlock()
+	for _, id := range ids {
+		m.Stop(id)
+	}
+	m.Wait()
+}
+
+// Wait 等待全部任务结束
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Pause 暂停一个任务，执行中的抓取继续完成，见 task.Task.Pause
+func (m *Manager) Pause(id string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	e.task.Pause()
+	return nil
+}
+
+// Resume 继续一个暂停的任务
+func (m *Manager) Resume(id string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	e.task.Resume()
+	return nil
+}
+
+// Status 返回一个任务的状态
+func (m *Manager) Status(id string) (Status, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[id]
+	if !ok {
+		return Status{}, false
+	}
+	return e.snapshot(), true
+}
+
+// Statuses 返回全部任务的状态，按任务编号排序
+func (m *Manager) Statuses() []Status {
+	m.mu.RLock()
+	list := make([]Status, 0, len(m.entries))
+	for _, e := range m.entries {
+		list = append(list, e.snapshot())
+	}
+	m.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// snapshot 返回任务的状态
+func (e *entry) snapshot() Status {
+	s := e.status
+	s.Paused = e.task.IsPaused()
+	s.Active = int(atomic.LoadInt32(&e.limiter.active))
+	return s
+}
+
+// limiter 一个任务的并发限制，从管理器共用的配额中获取
+type limiter struct {
+	slots  chan struct{}
+	active int32 // 占用的配额
+}
+
+// TryAcquire 获取一个配额
+func (l *limiter) TryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		atomic.AddInt32(&l.active, 1)
+		return true
+	default:
+		return false
+	}
+}
+
+// Release 归还一个配额
+func (l *limiter) Release() {
+	atomic.AddInt32(&l.active, -1)
+	<-l.slots
+}
@@ -0,0 +1,278 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// Feed RSS 或 Atom 订阅，支持 RSS 0.9x/2.0, RSS 1.0(RDF) 和 Atom 1.0
+type Feed struct {
+	Type        string       // rss, rdf, atom
+	Title       string       // 标题
+	Link        string       // 网站地址
+	Description string       // 描述
+	Updated     string       // 更新时间，保持原始格式
+	Entries     []*FeedEntry // 条目
+}
+
+// FeedEntry 订阅中的一个条目
+type FeedEntry struct {
+	ID         string   // 唯一标识，没有时使用链接
+	Title      string   // 标题
+	Link       string   // 链接
+	Summary    string   // 摘要
+	Content    string   // 全文
+	Author     string   // 作者
+	Published  string   // 发布时间，保持原始格式
+	Updated    string   // 更新时间，保持原始格式
+	Categories []string // 分类
+	Enclosure  string   // 附件地址，如播客的音频
+	XML        string   // 条目的原始XML，用于进一步提取
+}
+
+// FeedEntryKeys 条目转为数据行时的字段名，按顺序
+var FeedEntryKeys = []string{"id", "title", "link", "summary", "content", "author", "published", "updated", "categories", "enclosure"}
+
+// ParseFeed 解析 RSS 或 Atom 订阅
+func ParseFeed(body []byte) (*Feed, error) {
+	t, err := NewXML(body)
+	if err != nil {
+		return nil, err
+	}
+	if feed := t.Feed(); feed != nil {
+		return feed, nil
+	}
+	return nil, Errorf("not a rss or atom feed")
+}
+
+// Feed 将文档解析为订阅，不是 RSS 或 Atom 时返回 nil
+func (t *XML) Feed() *Feed {
+	root := xmlRoot(t.doc)
+	if root == nil {
+		return nil
+	}
+	feed := new(Feed)
+	switch {
+	case root.Data == "rss":
+		feed.Type = "rss"
+		channel := xmlChild(root, "channel")
+		if channel == nil {
+			return feed
+		}
+		feed.Title = xmlChildText(channel, "title")
+		feed.Link = xmlChildText(channel, "link")
+		feed.Description = xmlChildText(channel, "description")
+		feed.Updated = firstString(xmlChildText(channel, "lastBuildDate"), xmlChildText(channel, "pubDate"), xmlChildText(channel, "dc:date"))
+		for _, item := range xmlChildren(channel, "item") {
+			feed.Entries = append(feed.Entries, rssEntry(item))
+		}
+	case root.Data == "RDF":
+		feed.Type = "rdf"
+		if channel := xmlChild(root, "channel"); channel != nil {
+			feed.Title = xmlChildText(channel, "title")
+			feed.Link = xmlChildText(channel, "link")
+			feed.Description = xmlChildText(channel, "description")
+			feed.Updated = xmlChildText(channel, "dc:date")
+		}
+		for _, item := range xmlChildren(root, "item") {
+			feed.Entries = append(feed.Entries, rssEntry(item))
+		}
+	case root.Data == "feed":
+		feed.Type = "atom"
+		feed.Title = xmlChildText(root, "title")
+		feed.Link = atomLink(root, "alternate")
+		feed.Description = xmlChildText(root, "subtitle")
+		feed.Updated = xmlChildText(root, "updated")
+		for _, entry := range xmlChildren(root, "entry") {
+			feed.Entries = append(feed.Entries, atomEntry(entry))
+		}
+	default:
+		return nil
+	}
+	return feed
+}
+
+// rssEntry 解析 RSS 的 item
+func rssEntry(item *xmlquery.Node) *FeedEntry {
+	e := new(FeedEntry)
+	e.Title = xmlChildText(item, "title")
+	e.Link = xmlChildText(item, "link")
+	if guid := xmlChild(item, "guid"); guid != nil {
+		e.ID = strings.TrimSpace(guid.InnerText())
+		if e.Link == "" && guid.SelectAttr("isPermaLink") != "false" {
+			e.Link = e.ID
+		}
+	}
+	if e.Link == "" {
+		e.Link = item.SelectAttr("rdf:about")
+	}
+	e.ID = firstString(e.ID, item.SelectAttr("rdf:about"), e.Link)
+	e.Summary = xmlChildText(item, "description")
+	e.Content = xmlChildText(item, "content:encoded")
+	e.Author = firstString(xmlChildText(item, "author"), xmlChildText(item, "dc:creator"))
+	e.Published = firstString(xmlChildText(item, "pubDate"), xmlChildText(item, "dc:date"))
+	e.Updated = firstString(xmlChildText(item, "atom:updated"), e.Published)
+	for _, c := range append(xmlChildren(item, "category"), xmlChildren(item, "dc:subject")...) {
+		if v := strings.TrimSpace(c.InnerText()); v != "" {
+			e.Categories = append(e.Categories, v)
+		}
+	}
+	if enc := xmlChild(item, "enclosure"); enc != nil {
+		e.Enclosure = enc.SelectAttr("url")
+	} else if media := xmlChild(item, "media:content"); media != nil {
+		e.Enclosure = media.SelectAttr("url")
+	}
+	e.XML = item.OutputXML(true)
+	return e
+}
+
+// atomEntry 解析 Atom 的 entry
+func atomEntry(entry *xmlquery.Node) *FeedEntry {
+	e := new(FeedEntry)
+	e.ID = xmlChildText(entry, "id")
+	e.Title = xmlChildText(entry, "title")
+	e.Link = atomLink(entry, "alternate")
+	e.ID = firstString(e.ID, e.Link)
+	e.Summary = xmlChildText(entry, "summary")
+	e.Content = xmlChildText(entry, "content")
+	if author := xmlChild(entry, "author"); author != nil {
+		e.Author = firstString(xmlChildText(author, "name"), strings.TrimSpace(author.InnerText()))
+	}
+	e.Published = firstString(xmlChildText(entry, "published"), xmlChildText(entry, "issued"))
+	e.Updated = firstString(xmlChildText(entry, "updated"), xmlChildText(entry, "modified"), e.Published)
+	if e.Published == "" {
+		e.Published = e.Updated
+	}
+	for _, c := range xmlChildren(entry, "category") {
+		if v := firstString(c.SelectAttr("term"), c.SelectAttr("label")); v != "" {
+			e.Categories = append(e.Categories, v)
+		}
+	}
+	e.Enclosure = atomLink(entry, "enclosure")
+	e.XML = entry.OutputXML(true)
+	return e
+}
+
+// atomLink 返回指定 rel 的链接，alternate 同时匹配没有 rel 的链接
+func atomLink(n *xmlquery.Node, rel string) string {
+	for _, link := range xmlChildren(n, "link") {
+		r := link.SelectAttr("rel")
+		if r == rel || (r == "" && rel == "alternate") {
+			return strings.TrimSpace(link.SelectAttr("href"))
+		}
+	}
+	return ""
+}
+
+// Map 将条目转为数据行，键为 FeedEntryKeys
+func (e *FeedEntry) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"id":         e.ID,
+		"title":      e.Title,
+		"link":       e.Link,
+		"summary":    e.Summary,
+		"content":    e.Content,
+		"author":     e.Author,
+		"published":  e.Published,
+		"updated":    e.Updated,
+		"categories": e.Categories,
+		"enclosure":  e.Enclosure,
+	}
+}
+
+// Match 配置规则，返回订阅的属性 title, link, description, updated
+func (f *Feed) Match(rule string) string {
+	switch rule {
+	case "title":
+		return f.Title
+	case "link":
+		return f.Link
+	case "description":
+		return f.Description
+	case "updated":
+		return f.Updated
+	}
+	return ""
+}
+
+// Find 查找全部匹配，规则为 entries 时每个条目为一个匹配，值为条目的JSON
+func (f *Feed) Find(rule string) []Match {
+	var ms []Match
+	if rule == "entries" {
+		for _, e := range f.Entries {
+			b, _ := json.Marshal(e.Map())
+			m := newMatch(nil, string(b), -1)
+			m.Path = e.Link
+			ms = append(ms, m)
+		}
+		return ms
+	}
+	if v := f.Match(rule); v != "" {
+		ms = append(ms, newMatch(nil, v, -1))
+	}
+	return ms
+}
+
+// xmlRoot 返回文档的根元素
+func xmlRoot(doc *xmlquery.Node) *xmlquery.Node {
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == xmlquery.ElementNode {
+			return n
+		}
+		// 解析器会把缺少声明的文档的根元素放在声明节点下
+		if n.Type == xmlquery.DeclarationNode {
+			if root := xmlRoot(n); root != nil {
+				return root
+			}
+		}
+	}
+	return nil
+}
+
+// xmlChildren 返回指定名称的子元素，名称可以带 XMLNamespaces 中的前缀，
+// 不带前缀时匹配没有前缀的元素，以及 Atom 和 RSS 1.0 命名空间中的元素
+func xmlChildren(n *xmlquery.Node, name string) []*xmlquery.Node {
+	prefix, local := "", name
+	if p := strings.Index(name, ":"); p >= 0 {
+		prefix, local = name[:p], name[p+1:]
+	}
+	var nodes []*xmlquery.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != xmlquery.ElementNode || c.Data != local {
+			continue
+		}
+		if prefix == "" && (c.Prefix == "" || c.NamespaceURI == XMLNamespaces["atom"] || c.NamespaceURI == XMLNamespaces["rss"]) ||
+			prefix != "" && (c.Prefix == prefix || c.NamespaceURI == XMLNamespaces[prefix]) {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// xmlChild 返回指定名称的第一个子元素
+func xmlChild(n *xmlquery.Node, name string) *xmlquery.Node {
+	if nodes := xmlChildren(n, name); len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+// xmlChildText 返回指定名称的第一个子元素的文本
+func xmlChildText(n *xmlquery.Node, name string) string {
+	if c := xmlChild(n, name); c != nil {
+		return strings.TrimSpace(c.InnerText())
+	}
+	return ""
+}
+
+// firstString 返回第一个非空字符串
+func firstString(vs ...string) string {
+	for _, v := range vs {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Match 规则匹配的一个结果，用于调试规则
+type Match struct {
+	Value  string // 匹配到的值
+	Offset int    // 在内容中的字节偏移，无法确定时为 -1
+	Line   int    // 行号，从1开始，无法确定时为 0
+	Column int    // 列号，从1开始，无法确定时为 0
+	Path   string // 节点路径，HTML为CSS路径，JSON为JSON Path
+}
+
+// Finder 查找接口，返回规则的全部匹配及其位置
+type Finder interface {
+	Find(rule string) []Match
+}
+
+// newMatch 创建一个匹配结果，并根据偏移计算行列
+func newMatch(data []byte, value string, offset int) Match {
+	m := Match{Value: value, Offset: offset}
+	if offset >= 0 && offset <= len(data) {
+		m.Line, m.Column = Position(data, offset)
+	}
+	return m
+}
+
+// Position 计算字节偏移在内容中的行号和列号，均从1开始，列号按字符计算
+func Position(data []byte, offset int) (line, column int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte{'\n'}) + 1
+	if p := bytes.LastIndexByte(before, '\n'); p >= 0 {
+		before = before[p+1:]
+	}
+	column = len([]rune(string(before))) + 1
+	return line, column
+}
+
+// findSubmatch 使用正则查找全部匹配，返回第一个分组的值和位置，没有分组时返回整个匹配
+func findSubmatch(data []byte, re *regexp.Regexp) []Match {
+	var ms []Match
+	for _, loc := range re.FindAllSubmatchIndex(data, -1) {
+		start, end := loc[0], loc[1]
+		if len(loc) >= 4 && loc[2] >= 0 {
+			start, end = loc[2], loc[3]
+		}
+		ms = append(ms, newMatch(data, string(data[start:end]), start))
+	}
+	return ms
+}
+
+// Find 查找全部匹配及其位置，规则同 MatchAll
+func (t *Substring) Find(rule string) []Match {
+	if len(strings.Split(rule, "(*)")) != 2 {
+		return nil
+	}
+	re, err := RegexpRule(rule)
+	if err != nil {
+		return nil
+	}
+	return findSubmatch([]byte(t.data), re)
+}
+
+// Find 查找全部匹配及其位置，规则同 MatchAll
+func (t *Regexp) Find(rule string) []Match {
+	if rule == "" {
+		return nil
+	}
+	re, err := RegexpRule(rule)
+	if err != nil {
+		return nil
+	}
+	return findSubmatch([]byte(t.data), re)
+}
+
+// Find 查找全部匹配，JSON不记录位置，确定的路径 Path 为匹配值的JSON Path
+func (t *JSONPath) Find(rule string) []Match {
+	e := compileJSONPath(rule)
+	if e == nil {
+		return nil
+	}
+	var ms []Match
+	for i, v := range t.MatchAll(rule) {
+		m := Match{Value: string(t.Marshal(v)), Offset: -1}
+		if e.Definite() {
+			m.Path = rule + "[" + strconv.Itoa(i) + "]"
+		}
+		ms = append(ms, m)
+	}
+	if ms == nil && e.Definite() {
+		if v := t.Match(rule); v != nil {
+			ms = append(ms, Match{Value: string(t.Marshal(v)), Offset: -1, Path: rule})
+		}
+	}
+	return ms
+}
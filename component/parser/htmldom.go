@@ -2,6 +2,7 @@ package parser
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/net/html"
@@ -9,7 +10,9 @@ import (
 
 // HTMLDom HTML Selector 解析器，类似jQuery的选择器, document.querySelector
 type HTMLDom struct {
-	dom *goquery.Document // HTML Dom结构
+	body    []byte             // 原始内容
+	dom     *goquery.Document  // HTML Dom结构
+	offsets map[*html.Node]int // 节点在原始内容中的偏移，查找位置时初始化
 }
 
 // NewHTMLDom 创建一个HTML Selector 解析器
@@ -19,6 +22,7 @@ func NewHTMLDom(body []byte) (*HTMLDom, error) {
 	}
 	var err error
 	t := new(HTMLDom)
+	t.body = body
 	t.dom, err = goquery.NewDocumentFromReader(bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
@@ -27,26 +31,111 @@ func NewHTMLDom(body []byte) (*HTMLDom, error) {
 }
 
 // Match 配置规则，返回匹配到的值
+// 规则为CSS选择器，可以在末尾指定提取方式，默认为 innerHTML：
+//
+//	div.content::html        innerHTML
+//	div.content::outer       outerHTML
+//	div.content::text        文本，保留空白
+//	div.content::ntext       文本，合并空白并去掉两端空白
+//	a.title::attr(href)      属性值，没有该属性的元素将被跳过
 func (t *HTMLDom) Match(rule string) string {
-	dom := t.DomFind(rule)
-	if dom != nil {
-		v, _ := dom.Html()
-		return v
+	nodes, ex := t.selectNodes(rule)
+	for _, node := range nodes {
+		if v, ok := t.extract(node, ex); ok {
+			return v
+		}
 	}
 	return ""
 }
 
-// MatchAll 配置规则，返回匹配到的值，复数
+// MatchAll 配置规则，返回匹配到的值，复数，规则同 Match
 func (t *HTMLDom) MatchAll(rule string) []string {
 	var vals []string
-	dom := t.DomFind(rule)
-	for i := 0; i < dom.Size(); i++ {
-		node := dom.Get(i)
-		vals = append(vals, t.NodeHTML(node))
+	nodes, ex := t.selectNodes(rule)
+	for _, node := range nodes {
+		if v, ok := t.extract(node, ex); ok {
+			vals = append(vals, v)
+		}
 	}
 	return vals
 }
 
+const (
+	ExtractHTML      = iota // 提取 innerHTML
+	ExtractOuterHTML        // 提取 outerHTML
+	ExtractText             // 提取文本
+	ExtractNormText         // 提取文本，合并空白
+	ExtractAttr             // 提取属性
+)
+
+// extraction 选择器的提取方式
+type extraction struct {
+	mode int    // 提取方式
+	attr string // 属性名，仅 ExtractAttr 有效
+}
+
+// ParseSelectorRule 拆分规则为CSS选择器和提取方式，提取方式为 ExtractAttr 时返回属性名
+func ParseSelectorRule(rule string) (selector string, mode int, attr string) {
+	selector = rule
+	p := strings.LastIndex(rule, "::")
+	if p < 0 {
+		return selector, ExtractHTML, ""
+	}
+	suffix := strings.TrimSpace(rule[p+2:])
+	switch {
+	case suffix == "html":
+		mode = ExtractHTML
+	case suffix == "outer":
+		mode = ExtractOuterHTML
+	case suffix == "text":
+		mode = ExtractText
+	case suffix == "ntext":
+		mode = ExtractNormText
+	case strings.HasPrefix(suffix, "attr(") && strings.HasSuffix(suffix, ")"):
+		mode = ExtractAttr
+		attr = strings.TrimSpace(suffix[5 : len(suffix)-1])
+	default:
+		// 不认识的后缀，作为选择器本身处理
+		return selector, ExtractHTML, ""
+	}
+	return strings.TrimSpace(rule[:p]), mode, attr
+}
+
+// selectNodes 拆分规则并查找匹配的节点
+func (t *HTMLDom) selectNodes(rule string) ([]*html.Node, extraction) {
+	selector, mode, attr := ParseSelectorRule(rule)
+	ex := extraction{mode: mode, attr: attr}
+	dom := t.DomFind(selector)
+	if dom == nil {
+		return nil, ex
+	}
+	return dom.Nodes, ex
+}
+
+// extract 按提取方式获取节点的值，属性不存在时返回 false
+func (t *HTMLDom) extract(node *html.Node, ex extraction) (string, bool) {
+	switch ex.mode {
+	case ExtractOuterHTML:
+		var buf bytes.Buffer
+		if err := html.Render(&buf, node); err != nil {
+			return "", false
+		}
+		return buf.String(), true
+	case ExtractText:
+		return t.NodeText(node), true
+	case ExtractNormText:
+		return strings.Join(strings.Fields(t.NodeText(node)), " "), true
+	case ExtractAttr:
+		for _, a := range node.Attr {
+			if a.Key == ex.attr {
+				return a.Val, true
+			}
+		}
+		return "", false
+	}
+	return t.NodeHTML(node), true
+}
+
 // DomFind use document find a selector return html
 // you can use chrome to select the dom selector, then .Html() fetch the special dom html
 func (t *HTMLDom) DomFind(selector string) *goquery.Selection {
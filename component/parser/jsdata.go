@@ -0,0 +1,357 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSData 页面中嵌入的 JavaScript 数据解析器，不需要浏览器
+//
+// 规则为变量名或者 JSONP 回调：
+//
+//	window.__INITIAL_STATE__  查找变量赋值 window.__INITIAL_STATE__ = {...}，同时匹配 var/let/const 声明
+//	__NEXT_DATA__             没有 . 的名字同时匹配对象属性 "__NEXT_DATA__": {...} 和 id 为该名字的 <script>
+//	callback()                查找 JSONP 调用 callback({...})
+//	()                        任意回调名的 JSONP 调用
+//
+// 从赋值或调用处取出括号配对的对象或数组字面量，兼容单引号，末尾逗号，不带引号的键名和注释，
+// 转换为标准的 JSON 返回，可以作为 JSONPath 的内容
+type JSData struct {
+	body []byte // 原始内容
+}
+
+// NewJSData 创建一个 JavaScript 数据解析器
+func NewJSData(body []byte) (*JSData, error) {
+	if body == nil {
+		return nil, Errorf("body is empty")
+	}
+	t := new(JSData)
+	t.body = body
+	return t, nil
+}
+
+// Match 配置规则，返回第一个匹配到的数据，JSON格式
+func (t *JSData) Match(rule string) string {
+	for _, m := range t.Find(rule) {
+		return m.Value
+	}
+	return ""
+}
+
+// MatchAll 配置规则，返回全部匹配到的数据，JSON格式
+func (t *JSData) MatchAll(rule string) []string {
+	var vals []string
+	for _, m := range t.Find(rule) {
+		vals = append(vals, m.Value)
+	}
+	return vals
+}
+
+// Find 查找全部匹配及其位置，位置为字面量的开始
+func (t *JSData) Find(rule string) []Match {
+	re := jsDataRegexp(strings.TrimSpace(rule))
+	if re == nil {
+		return nil
+	}
+	var ms []Match
+	for _, loc := range re.FindAllIndex(t.body, -1) {
+		start := loc[1]
+		for start < len(t.body) && isJSSpace(t.body[start]) {
+			start++
+		}
+		var v []byte
+		var err error
+		if bytes.HasPrefix(t.body[start:], []byte("JSON.parse(")) {
+			v, err = jsonParseArg(t.body, start+len("JSON.parse("))
+		} else if end := balancedEnd(t.body, start); end >= 0 {
+			v, err = JSToJSON(t.body[start:end])
+		} else {
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		ms = append(ms, newMatch(t.body, string(v), start))
+	}
+	return ms
+}
+
+// jsonParseArg 取出 JSON.parse("...") 的字符串参数并解码
+func jsonParseArg(data []byte, start int) ([]byte, error) {
+	for start < len(data) && isJSSpace(data[start]) {
+		start++
+	}
+	if start >= len(data) || (data[start] != '"' && data[start] != '\'' && data[start] != '`') {
+		return nil, Errorf("JSON.parse argument is not a string")
+	}
+	end := stringEnd(data, start)
+	if end < 0 {
+		return nil, Errorf("JSON.parse argument is unterminated")
+	}
+	var s string
+	if err := json.Unmarshal([]byte(jsQuote(data[start+1:end])), &s); err != nil {
+		return nil, err
+	}
+	return JSToJSON([]byte(s))
+}
+
+// jsDataRegexp 将规则编译为查找字面量开始位置的正则，匹配结束的位置之后是字面量
+func jsDataRegexp(rule string) *regexp.Regexp {
+	var expr string
+	switch {
+	case rule == "":
+		return nil
+	case rule == "()":
+		expr = `(?:^|[^\w$.])[A-Za-z_$][\w$.]*\s*\(`
+	case strings.HasSuffix(rule, "()"):
+		expr = `(?:^|[^\w$.])` + jsNameExpr(rule[:len(rule)-2]) + `\s*\(`
+	default:
+		name := jsNameExpr(rule)
+		expr = `(?:^|[^\w$.])(?:(?:var|let|const)\s+)?` + name + `\s*=\s*`
+		if !strings.Contains(rule, ".") {
+			// 对象属性和 <script id="name" type="application/json">
+			expr += `|["']` + regexp.QuoteMeta(rule) + `["']\s*:\s*` +
+				`|<script[^>]*\sid=["']?` + regexp.QuoteMeta(rule) + `["']?[^>]*>\s*`
+		}
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// jsNameExpr 变量名的正则，window.a 同时匹配 window["a"] 和 window['a']
+func jsNameExpr(name string) string {
+	var parts []string
+	for i, p := range strings.Split(name, ".") {
+		q := regexp.QuoteMeta(p)
+		if i == 0 {
+			parts = append(parts, q)
+			continue
+		}
+		parts = append(parts, `(?:\.`+q+`|\[["']`+q+`["']\])`)
+	}
+	return strings.Join(parts, `\s*`)
+}
+
+// balancedEnd 返回从 start 开始的对象或数组字面量的结束位置，跳过字符串和注释，不是字面量或不完整时返回 -1
+func balancedEnd(data []byte, start int) int {
+	if start >= len(data) || (data[start] != '{' && data[start] != '[') {
+		return -1
+	}
+	depth := 0
+	for i := start; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '"', '\'', '`':
+			if i = stringEnd(data, i); i < 0 {
+				return -1
+			}
+		case '/':
+			if i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*') {
+				i = commentEnd(data, i) - 1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// stringEnd 返回字符串结束引号的位置，未结束返回 -1
+func stringEnd(data []byte, start int) int {
+	quote := data[start]
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		case '\n':
+			if quote != '`' {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// commentEnd 返回注释之后的位置
+func commentEnd(data []byte, start int) int {
+	if data[start+1] == '/' {
+		if p := bytes.IndexByte(data[start:], '\n'); p >= 0 {
+			return start + p + 1
+		}
+		return len(data)
+	}
+	if p := bytes.Index(data[start+2:], []byte("*/")); p >= 0 {
+		return start + 2 + p + 2
+	}
+	return len(data)
+}
+
+// isJSSpace 是否为空白
+func isJSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// JSToJSON 将 JavaScript 的对象或数组字面量转换为标准的 JSON
+// 支持单引号和模板字符串(不含表达式)，不带引号的键名，末尾逗号，注释，
+// undefined, NaN, Infinity 转为 null，压缩代码中的 !0, !1 转为 true, false
+func JSToJSON(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case isJSSpace(c):
+			continue
+		case c == '"' || c == '\'' || c == '`':
+			end := stringEnd(data, i)
+			if end < 0 {
+				return nil, Errorf("JSToJSON: unterminated string at %d", i)
+			}
+			if c == '`' && bytes.Contains(data[i:end], []byte("${")) {
+				return nil, Errorf("JSToJSON: template expression at %d", i)
+			}
+			buf.WriteString(jsQuote(data[i+1 : end]))
+			i = end
+		case c == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			i = commentEnd(data, i) - 1
+		case c == ',':
+			// 去掉末尾逗号
+			j := i + 1
+			for j < len(data) {
+				if isJSSpace(data[j]) {
+					j++
+				} else if data[j] == '/' && j+1 < len(data) && (data[j+1] == '/' || data[j+1] == '*') {
+					j = commentEnd(data, j)
+				} else {
+					break
+				}
+			}
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				continue
+			}
+			buf.WriteByte(c)
+		case c == '!' && i+1 < len(data) && (data[i+1] == '0' || data[i+1] == '1'):
+			if data[i+1] == '0' {
+				buf.WriteString("true")
+			} else {
+				buf.WriteString("false")
+			}
+			i++
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(data) && strings.IndexByte("0123456789.eExXabcdefABCDEF+-", data[j]) >= 0 {
+				j++
+			}
+			buf.WriteString(jsNumber(string(data[i:j])))
+			i = j - 1
+		case c == '_' || c == '$' || (c|0x20 >= 'a' && c|0x20 <= 'z') || c >= utf8.RuneSelf:
+			j := i + 1
+			for j < len(data) && (data[j] == '_' || data[j] == '$' || (data[j]|0x20 >= 'a' && data[j]|0x20 <= 'z') ||
+				(data[j] >= '0' && data[j] <= '9') || data[j] >= utf8.RuneSelf) {
+				j++
+			}
+			word := string(data[i:j])
+			k := j
+			for k < len(data) && isJSSpace(data[k]) {
+				k++
+			}
+			switch {
+			case k < len(data) && data[k] == ':':
+				buf.WriteString(strconv.Quote(word))
+			case word == "true" || word == "false" || word == "null":
+				buf.WriteString(word)
+			case word == "undefined" || word == "NaN" || word == "Infinity":
+				buf.WriteString("null")
+			default:
+				return nil, Errorf("JSToJSON: unexpected identifier %q at %d", word, i)
+			}
+			i = j - 1
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, Errorf("JSToJSON: invalid object literal")
+	}
+	return buf.Bytes(), nil
+}
+
+// jsQuote 将 JavaScript 字符串内容转换为 JSON 字符串
+func jsQuote(s []byte) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch n := s[i]; n {
+			case '\'', '`':
+				buf.WriteByte(n)
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+				buf.WriteByte('\\')
+				buf.WriteByte(n)
+			case 'x':
+				// \xHH 转为 \u00HH
+				buf.WriteString(`\u00`)
+			case '\n':
+				// 行尾的续行符
+			default:
+				buf.WriteByte(n)
+			}
+		case c == '"':
+			buf.WriteString(`\"`)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == '\t':
+			buf.WriteString(`\t`)
+		case c < 0x20:
+			buf.WriteString(`\u00`)
+			buf.WriteString(strconv.FormatInt(int64(c)>>4, 16))
+			buf.WriteString(strconv.FormatInt(int64(c)&0xf, 16))
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// jsNumber 将 JavaScript 数字转换为 JSON 数字，十六进制转为十进制，.5 补全为 0.5
+func jsNumber(s string) string {
+	neg := strings.HasPrefix(s, "-")
+	n := strings.TrimPrefix(s, "-")
+	if strings.HasPrefix(n, "0x") || strings.HasPrefix(n, "0X") {
+		if v, err := strconv.ParseInt(n[2:], 16, 64); err == nil {
+			if neg {
+				v = -v
+			}
+			return strconv.FormatInt(v, 10)
+		}
+		return s
+	}
+	if strings.HasPrefix(n, ".") {
+		n = "0" + n
+	}
+	if strings.HasSuffix(n, ".") {
+		n = n[:len(n)-1]
+	}
+	if neg {
+		return "-" + n
+	}
+	return n
+}
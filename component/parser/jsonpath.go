@@ -2,7 +2,9 @@ package parser
 
 import (
 	"encoding/json"
+	"sync"
 
+	"github.com/safeie/spider/common/log"
 	"github.com/safeie/spider/common/simplejson"
 )
 
@@ -26,21 +28,58 @@ func NewJSONPath(body []byte) (*JSONPath, error) {
 }
 
 // Match 配置规则，返回匹配到的值
+// 确定的路径(如 $.data.list[0])返回该值，包含通配符，递归，切片，联合或过滤的路径返回全部匹配值组成的数组
 func (t *JSONPath) Match(rule string) interface{} {
-	if re := t.parser.Path(rule); re != nil {
-		return re.Interface()
+	e := compileJSONPath(rule)
+	if e == nil {
+		return nil
+	}
+	vals := e.Eval(t.parser.Interface())
+	if !e.Definite() {
+		return vals
+	}
+	if len(vals) > 0 {
+		return vals[0]
 	}
 	return nil
 }
 
 // MatchAll 配置规则，返回匹配到的值，复数
+// 确定的路径返回该数组的元素，否则返回全部匹配值
 func (t *JSONPath) MatchAll(rule string) []interface{} {
-	if re := t.parser.Path(rule); re != nil {
-		return re.MustSlice()
+	e := compileJSONPath(rule)
+	if e == nil {
+		return nil
+	}
+	vals := e.Eval(t.parser.Interface())
+	if !e.Definite() {
+		return vals
+	}
+	if len(vals) > 0 {
+		if v, ok := vals[0].([]interface{}); ok {
+			return v
+		}
 	}
 	return nil
 }
 
+// jsonPathCache 编译过的表达式，规则在每个页面上重复执行，避免重复编译
+var jsonPathCache sync.Map
+
+// compileJSONPath 编译表达式，出错时记录日志并返回 nil
+func compileJSONPath(rule string) *simplejson.Expr {
+	if v, ok := jsonPathCache.Load(rule); ok {
+		return v.(*simplejson.Expr)
+	}
+	e, err := simplejson.Compile(rule)
+	if err != nil {
+		log.Errorf("JSONPath Compile Error: %v", err)
+		return nil
+	}
+	jsonPathCache.Store(rule, e)
+	return e
+}
+
 // Marshal 编码JSON
 func (t *JSONPath) Marshal(v interface{}) []byte {
 	b, _ := json.Marshal(v)
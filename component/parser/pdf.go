@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDFPageSeparator PDF 转为文本后页与页之间的分隔符(换页符)
+const PDFPageSeparator = "\f"
+
+// IsPDF 内容是否为 PDF 文档
+func IsPDF(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(body, " \t\r\n"), []byte("%PDF-"))
+}
+
+// PDFPages 提取 PDF 每一页的文本，同一行的文字合并为一行，行按从上到下的顺序
+// 只提取文本层，扫描件等没有文本的页为空字符串
+func PDFPages(body []byte) (pages []string, err error) {
+	defer func() {
+		// 解析损坏的文档时可能 panic
+		if r := recover(); r != nil {
+			pages, err = nil, Errorf("pdf: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			pages = append(pages, "")
+			continue
+		}
+		rows, err := p.GetTextByRow()
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, row := range rows {
+			var b strings.Builder
+			for _, t := range row.Content {
+				b.WriteString(t.S)
+			}
+			if line := strings.TrimSpace(b.String()); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		pages = append(pages, strings.Join(lines, "\n"))
+	}
+	return pages, nil
+}
+
+// PDFText 提取 PDF 的全部文本，页与页之间以 PDFPageSeparator 分隔
+func PDFText(body []byte) ([]byte, error) {
+	pages, err := PDFPages(body)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(pages, PDFPageSeparator)), nil
+}
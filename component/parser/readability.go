@@ -0,0 +1,443 @@
+package parser
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Readability 正文提取解析器，对页面的节点打分，找出文章的正文，标题，作者，发布时间和首图，
+// 不需要为每个站点编写选择器
+//
+// 规则为要提取的部分：
+//
+//	content  正文 HTML，已去掉脚本，样式，导航和广告等
+//	text     正文文本，段落之间以空行分隔
+//	title    标题
+//	byline   作者
+//	date     发布时间，保持页面中的原始格式
+//	image    首图地址
+//	images   正文中的全部图片地址，仅 MatchAll
+type Readability struct {
+	body    []byte            // 原始内容
+	doc     *goquery.Document // HTML Dom结构，打分前会删除无关节点
+	top     *html.Node        // 正文节点
+	nodes   []*html.Node      // 正文节点及并入正文的兄弟节点
+	meta    map[string]string // meta 标签，键为 name 或 property
+	offset  int               // 正文节点在原始内容中的偏移，无法确定时为 -1
+	article map[string]string // 已提取的部分
+}
+
+// NewReadability 创建一个正文提取解析器
+func NewReadability(body []byte) (*Readability, error) {
+	if body == nil {
+		return nil, Errorf("body is empty")
+	}
+	var err error
+	t := new(Readability)
+	t.body = body
+	t.doc, err = goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	t.meta = make(map[string]string)
+	t.doc.Find("meta[content]").Each(func(i int, s *goquery.Selection) {
+		content, _ := s.Attr("content")
+		for _, key := range []string{"property", "name", "itemprop"} {
+			if v, ok := s.Attr(key); ok && v != "" {
+				if _, ok := t.meta[strings.ToLower(v)]; !ok {
+					t.meta[strings.ToLower(v)] = strings.TrimSpace(content)
+				}
+			}
+		}
+	})
+	t.article = make(map[string]string)
+	// 标题，作者和时间在删除无关节点之前提取
+	t.article["title"] = t.title()
+	t.article["byline"] = t.byline()
+	t.article["date"] = t.date()
+	t.grab()
+	return t, nil
+}
+
+// Match 配置规则，返回匹配到的值
+func (t *Readability) Match(rule string) string {
+	switch rule {
+	case "content":
+		return t.content()
+	case "text":
+		return t.text()
+	case "image":
+		if v := t.meta["og:image"]; v != "" {
+			return v
+		}
+		if imgs := t.images(); len(imgs) > 0 {
+			return imgs[0]
+		}
+		return ""
+	case "images":
+		return strings.Join(t.images(), "\n")
+	}
+	return t.article[rule]
+}
+
+// MatchAll 配置规则，返回匹配到的值，复数，只有 images 返回多个值
+func (t *Readability) MatchAll(rule string) []string {
+	if rule == "images" {
+		return t.images()
+	}
+	if v := t.Match(rule); v != "" {
+		return []string{v}
+	}
+	return nil
+}
+
+// Find 查找匹配及其位置，content 和 text 的位置为正文节点的开始标签
+func (t *Readability) Find(rule string) []Match {
+	var ms []Match
+	for _, v := range t.MatchAll(rule) {
+		offset := -1
+		if rule == "content" || rule == "text" {
+			offset = t.offset
+		}
+		ms = append(ms, newMatch(t.body, v, offset))
+	}
+	return ms
+}
+
+var (
+	reUnlikely      = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|disqus|extra|foot|header|menu|modal|nav|pager|pagination|popup|related|remark|rss|share|shoutbox|sidebar|social|sponsor|subscribe|tags|tool|widget|advert|ad-|ads|recommend|copyright`)
+	reMaybe         = regexp.MustCompile(`(?i)and|article|body|column|main|shadow|content|entry|post|text|story|detail`)
+	rePositive      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|text|blog|story|detail|正文`)
+	reNegative      = regexp.MustCompile(`(?i)hidden|banner|combx|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|recommend|copyright`)
+	reByline        = regexp.MustCompile(`(?i)byline|author|writtenby|p-author|editor|source`)
+	reTitleSep      = regexp.MustCompile(`\s*[-|_–—»]\s*`)
+	reDate          = regexp.MustCompile(`\d{4}\s*[-/.年]\s*\d{1,2}\s*[-/.月]\s*\d{1,2}\s*日?(\s*\d{1,2}:\d{2}(:\d{2})?)?`)
+	readabilityTags = map[string]bool{"p": true, "pre": true, "td": true, "blockquote": true}
+	blockTags       = map[string]bool{"a": false, "blockquote": true, "dl": true, "div": true, "img": false, "ol": true, "p": true, "pre": true, "table": true, "ul": true, "section": true, "article": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true}
+	removeTags      = "script, style, noscript, iframe, form, nav, aside, footer, header, button, input, select, textarea, svg, link, meta"
+)
+
+// title 标题，优先使用 og:title，其次是包含在 <title> 中的唯一的 h1，最后是 <title> 中分隔符之前最长的部分
+func (t *Readability) title() string {
+	if v := t.meta["og:title"]; v != "" {
+		return v
+	}
+	title := normalizeSpace(t.doc.Find("title").First().Text())
+	h1 := t.doc.Find("h1")
+	if h1.Length() == 1 {
+		if v := normalizeSpace(h1.Text()); v != "" && (title == "" || strings.Contains(title, v)) {
+			return v
+		}
+	}
+	if parts := reTitleSep.Split(title, -1); len(parts) > 1 {
+		longest := ""
+		for _, p := range parts {
+			if utf8.RuneCountInString(p) > utf8.RuneCountInString(longest) {
+				longest = p
+			}
+		}
+		return longest
+	}
+	return title
+}
+
+// byline 作者，优先使用 meta 标签，其次是 rel=author 或类名像作者的短文本
+func (t *Readability) byline() string {
+	for _, key := range []string{"author", "article:author", "dc.creator", "byl"} {
+		if v := t.meta[key]; v != "" && !strings.HasPrefix(v, "http") {
+			return v
+		}
+	}
+	var byline string
+	t.doc.Find(`[rel="author"], [itemprop="author"], [class], [id]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if goquery.NodeName(s) == "body" || goquery.NodeName(s) == "html" {
+			return true
+		}
+		rel, _ := s.Attr("rel")
+		prop, _ := s.Attr("itemprop")
+		if rel != "author" && prop != "author" && !reByline.MatchString(nodeClassID(s.Get(0))) {
+			return true
+		}
+		v := normalizeSpace(s.Text())
+		if v == "" || utf8.RuneCountInString(v) > 100 {
+			return true
+		}
+		byline = v
+		return false
+	})
+	return byline
+}
+
+// date 发布时间，优先使用 meta 标签和 <time>，其次是页面文本中的第一个日期
+func (t *Readability) date() string {
+	for _, key := range []string{"article:published_time", "datepublished", "pubdate", "publishdate", "publish_date", "dc.date", "date", "og:release_date"} {
+		if v := t.meta[key]; v != "" {
+			return v
+		}
+	}
+	if s := t.doc.Find("time[datetime]").First(); s.Length() > 0 {
+		v, _ := s.Attr("datetime")
+		return strings.TrimSpace(v)
+	}
+	body := t.doc.Find("body").Clone()
+	body.Find(removeTags).Remove()
+	return strings.TrimSpace(reDate.FindString(body.Text()))
+}
+
+// grab 删除无关节点，对段落打分，选出正文节点，并合并分数接近的兄弟节点
+func (t *Readability) grab() {
+	// 删除节点后无法再按标签顺序对应原始内容，先记录偏移
+	offsets := nodeOffsets(t.body, t.doc.Nodes)
+	t.offset = -1
+	defer func() {
+		if v, ok := offsets[t.top]; ok {
+			t.offset = v
+		}
+	}()
+
+	t.doc.Find(removeTags).Remove()
+	// 类名和 id 像导航，评论，广告的节点
+	t.doc.Find("body *").Each(func(i int, s *goquery.Selection) {
+		n := s.Get(0)
+		if n.Parent == nil || n.Data == "article" || n.Data == "main" {
+			return
+		}
+		if ci := nodeClassID(n); reUnlikely.MatchString(ci) && !reMaybe.MatchString(ci) {
+			n.Parent.RemoveChild(n)
+		}
+	})
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+	addCandidate := func(n *html.Node) {
+		if _, ok := scores[n]; !ok {
+			scores[n] = initScore(n)
+			candidates = append(candidates, n)
+		}
+	}
+	t.doc.Find("body *").Each(func(i int, s *goquery.Selection) {
+		n := s.Get(0)
+		if !readabilityTags[n.Data] && !(n.Data == "div" && !hasBlockChild(n)) {
+			return
+		}
+		text := normalizeSpace(s.Text())
+		size := utf8.RuneCountInString(text)
+		if size < 25 || n.Parent == nil {
+			return
+		}
+		// 基础分1分，每个逗号1分，每100个字1分，最多3分
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")+strings.Count(text, "。"))
+		score += math.Min(float64(size)/100, 3)
+		parent := n.Parent
+		addCandidate(parent)
+		scores[parent] += score
+		if grand := parent.Parent; grand != nil && grand.Type == html.ElementNode {
+			addCandidate(grand)
+			scores[grand] += score / 2
+		}
+	})
+
+	var top *html.Node
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(n)
+		if top == nil || scores[n] > scores[top] {
+			top = n
+		}
+	}
+	if top == nil {
+		if body := t.doc.Find("body"); body.Length() > 0 {
+			t.top = body.Get(0)
+			t.nodes = []*html.Node{t.top}
+		}
+		return
+	}
+	t.top = top
+
+	// 兄弟节点分数接近，或者是链接较少的长段落时，并入正文
+	threshold := math.Max(10, scores[top]*0.2)
+	if top.Parent == nil {
+		t.nodes = []*html.Node{top}
+		return
+	}
+	for c := top.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if c == top {
+			t.nodes = append(t.nodes, c)
+			continue
+		}
+		if v, ok := scores[c]; ok && v >= threshold {
+			t.nodes = append(t.nodes, c)
+			continue
+		}
+		if c.Data == "p" {
+			text := normalizeSpace(nodeText(c))
+			size := utf8.RuneCountInString(text)
+			ld := linkDensity(c)
+			if (size > 80 && ld < 0.25) || (size > 0 && ld == 0 && strings.ContainsAny(text, ".。")) {
+				t.nodes = append(t.nodes, c)
+			}
+		}
+	}
+}
+
+// content 正文 HTML
+func (t *Readability) content() string {
+	if v, ok := t.article["content"]; ok {
+		return v
+	}
+	var buf bytes.Buffer
+	for _, n := range t.nodes {
+		if n == t.top {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				html.Render(&buf, c)
+			}
+		} else {
+			html.Render(&buf, n)
+		}
+	}
+	t.article["content"] = strings.TrimSpace(buf.String())
+	return t.article["content"]
+}
+
+// text 正文文本，块级元素之间以空行分隔
+func (t *Readability) text() string {
+	if v, ok := t.article["text"]; ok {
+		return v
+	}
+	var paras []string
+	var line bytes.Buffer
+	flush := func() {
+		if v := normalizeSpace(line.String()); v != "" {
+			paras = append(paras, v)
+		}
+		line.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			line.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if n.Data == "br" {
+				flush()
+				return
+			}
+		}
+		block := n.Type == html.ElementNode && (blockTags[n.Data] || n.Data == "li" || n.Data == "tr")
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			flush()
+		}
+	}
+	for _, n := range t.nodes {
+		walk(n)
+		flush()
+	}
+	t.article["text"] = strings.Join(paras, "\n\n")
+	return t.article["text"]
+}
+
+// images 正文中的图片地址，优先使用懒加载属性
+func (t *Readability) images() []string {
+	var imgs []string
+	for _, n := range t.nodes {
+		goquery.NewDocumentFromNode(n).Find("img").Each(func(i int, s *goquery.Selection) {
+			for _, key := range []string{"data-src", "data-original", "src"} {
+				if v, ok := s.Attr(key); ok && strings.TrimSpace(v) != "" && !strings.HasPrefix(v, "data:") {
+					imgs = append(imgs, strings.TrimSpace(v))
+					return
+				}
+			}
+		})
+	}
+	return imgs
+}
+
+// initScore 候选节点的初始分数，按标签和类名计算
+func initScore(n *html.Node) float64 {
+	var score float64
+	switch n.Data {
+	case "article":
+		score = 10
+	case "div", "section", "main":
+		score = 5
+	case "pre", "td", "blockquote":
+		score = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score = -5
+	}
+	ci := nodeClassID(n)
+	if rePositive.MatchString(ci) {
+		score += 25
+	}
+	if reNegative.MatchString(ci) {
+		score -= 25
+	}
+	return score
+}
+
+// hasBlockChild 判断节点是否包含块级子元素
+func hasBlockChild(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.Data] {
+			return true
+		}
+	}
+	return false
+}
+
+// linkDensity 链接文本占全部文本的比例
+func linkDensity(n *html.Node) float64 {
+	size := utf8.RuneCountInString(normalizeSpace(nodeText(n)))
+	if size == 0 {
+		return 0
+	}
+	var links int
+	goquery.NewDocumentFromNode(n).Find("a").Each(func(i int, s *goquery.Selection) {
+		links += utf8.RuneCountInString(normalizeSpace(s.Text()))
+	})
+	return float64(links) / float64(size)
+}
+
+// nodeText 节点的全部文本
+func nodeText(n *html.Node) string {
+	var buf bytes.Buffer
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return buf.String()
+}
+
+// nodeClassID 节点的类名和 id
+func nodeClassID(n *html.Node) string {
+	var v []string
+	for _, a := range n.Attr {
+		if a.Key == "class" || a.Key == "id" {
+			v = append(v, a.Val)
+		}
+	}
+	return strings.Join(v, " ")
+}
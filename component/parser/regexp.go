@@ -22,15 +22,19 @@ func NewRegexp(body []byte) (*Regexp, error) {
 	return t, nil
 }
 
+// RegexpRule 将简化的正则规则转为正则表达式，(*) 为要获取的值，* 匹配任意内容，其它字符按原样匹配
+func RegexpRule(rule string) (*regexp.Regexp, error) {
+	rule = strings.Replace(regexp.QuoteMeta(rule), "\\(\\*\\)", "(.*)", 1)
+	rule = strings.Replace(rule, "\\*", ".*", -1)
+	return regexp.Compile("(?Uis)" + rule)
+}
+
 // Match 配置规则，返回匹配到的值
 func (t *Regexp) Match(rule string) string {
 	if rule == "" {
 		return ""
 	}
-	rule = strings.Replace(regexp.QuoteMeta(rule), "\\(\\*\\)", "(.*)", 1)
-	rule = strings.Replace(rule, "\\*", ".*", -1)
-	rule = "(?Uis)" + rule
-	re, err := regexp.Compile(rule)
+	re, err := RegexpRule(rule)
 	if err != nil {
 		log.Errorf("HTML.RegexpMatch Compile Error: %v", err)
 		return ""
@@ -48,10 +52,7 @@ func (t *Regexp) MatchAll(rule string) []string {
 	if rule == "" {
 		return nil
 	}
-	rule = strings.Replace(regexp.QuoteMeta(rule), "\\(\\*\\)", "(.*)", 1)
-	rule = strings.Replace(rule, "\\*", ".*", -1)
-	rule = "(?Uis)" + rule
-	re, err := regexp.Compile(rule)
+	re, err := RegexpRule(rule)
 	if err != nil {
 		log.Errorf("HTML.RegexpMatch Compile Error: %v", err)
 		return nil
@@ -75,3 +76,47 @@ func (t *Regexp) Regexp(pattern string) []string {
 	}
 	return re.FindStringSubmatch(t.data)
 }
+
+// Submatch 使用编译好的正则匹配内容，返回第一个匹配及其分组，是真正的正则
+func (t *Regexp) Submatch(re *regexp.Regexp) []string {
+	if re == nil {
+		return nil
+	}
+	return re.FindStringSubmatch(t.data)
+}
+
+// SubmatchAll 使用编译好的正则匹配内容，返回全部匹配及其分组，是真正的正则
+func (t *Regexp) SubmatchAll(re *regexp.Regexp) [][]string {
+	if re == nil {
+		return nil
+	}
+	return re.FindAllStringSubmatch(t.data, -1)
+}
+
+// FindSubmatch 使用编译好的正则查找全部匹配及其位置，有命名分组时值为 name=value 的列表
+func (t *Regexp) FindSubmatch(re *regexp.Regexp) []Match {
+	if re == nil {
+		return nil
+	}
+	data := []byte(t.data)
+	names := re.SubexpNames()
+	var ms []Match
+	for _, loc := range re.FindAllSubmatchIndex(data, -1) {
+		var named []string
+		for i := 1; i < len(names); i++ {
+			if names[i] != "" && loc[2*i] >= 0 {
+				named = append(named, names[i]+"="+string(data[loc[2*i]:loc[2*i+1]]))
+			}
+		}
+		if len(named) > 0 {
+			ms = append(ms, newMatch(data, strings.Join(named, ", "), loc[0]))
+			continue
+		}
+		start, end := loc[0], loc[1]
+		if len(loc) >= 4 && loc[2] >= 0 {
+			start, end = loc[2], loc[3]
+		}
+		ms = append(ms, newMatch(data, string(data[start:end]), start))
+	}
+	return ms
+}
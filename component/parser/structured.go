@@ -0,0 +1,348 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// StructuredData HTML 页面中嵌入的结构化数据解析器，支持 JSON-LD, microdata 和 OpenGraph
+//
+// 所有数据块统一为 map[string]interface{}，类型保存在 @type 中，
+// schema.org 的类型去掉前缀(http://schema.org/Product 为 Product)，
+// OpenGraph 的 meta 标签作为类型为 OpenGraph 的一个数据块，属性去掉 og: 前缀
+type StructuredData struct {
+	items []map[string]interface{} // 顶层数据块，按在页面中出现的顺序
+}
+
+// NewStructuredData 创建一个结构化数据解析器
+func NewStructuredData(body []byte) (*StructuredData, error) {
+	if body == nil {
+		return nil, Errorf("body is empty")
+	}
+	dom, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	t := new(StructuredData)
+	t.parseJSONLD(dom)
+	t.parseMicrodata(dom)
+	t.parseOpenGraph(dom)
+	return t, nil
+}
+
+// Items 返回全部顶层数据块
+func (t *StructuredData) Items() []map[string]interface{} {
+	return t.items
+}
+
+// Match 配置规则，返回匹配到的值
+// 规则为 类型.路径，如 Product.offers.price，类型可以是嵌套的数据块(如 Offer.price)，
+// 只有类型时返回整个数据块，类型为 * 时匹配全部顶层数据块，
+// 路径中遇到数组时在每个元素上继续查找，数字表示数组下标(如 Product.image.0)
+func (t *StructuredData) Match(rule string) interface{} {
+	vals := t.find(rule)
+	if len(vals) == 0 {
+		return nil
+	}
+	return vals[0]
+}
+
+// MatchAll 配置规则，返回匹配到的值，复数，规则同 Match，值为数组时展开
+func (t *StructuredData) MatchAll(rule string) []interface{} {
+	var vals []interface{}
+	for _, v := range t.find(rule) {
+		if arr, ok := v.([]interface{}); ok {
+			vals = append(vals, arr...)
+		} else {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
+
+// find 查找规则匹配的全部值
+func (t *StructuredData) find(rule string) []interface{} {
+	typ, path := rule, ""
+	if p := strings.Index(rule, "."); p >= 0 {
+		typ, path = rule[:p], rule[p+1:]
+	}
+	if typ == "" {
+		return nil
+	}
+	var keys []string
+	if path != "" {
+		keys = strings.Split(path, ".")
+	}
+
+	var vals []interface{}
+	for _, item := range t.typed(typ) {
+		vals = append(vals, walkStructured(item, keys)...)
+	}
+	return vals
+}
+
+// typed 递归查找指定类型的数据块，类型为 * 时返回全部顶层数据块，
+// 顶层数据块按页面中的顺序，嵌套的数据块按属性名排序，结果是确定的
+func (t *StructuredData) typed(typ string) []map[string]interface{} {
+	if typ == "*" {
+		return t.items
+	}
+	var found []map[string]interface{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if hasStructuredType(v, typ) {
+				found = append(found, v)
+			}
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(v[k])
+			}
+		case []interface{}:
+			for _, c := range v {
+				walk(c)
+			}
+		}
+	}
+	for _, item := range t.items {
+		walk(item)
+	}
+	return found
+}
+
+// hasStructuredType 判断数据块是否为指定类型，@type 可以是数组
+func hasStructuredType(item map[string]interface{}, typ string) bool {
+	switch v := item["@type"].(type) {
+	case string:
+		return v == typ
+	case []interface{}:
+		for _, s := range v {
+			if s == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// walkStructured 按路径查找值，遇到数组时展开
+func walkStructured(v interface{}, keys []string) []interface{} {
+	if len(keys) == 0 {
+		if v == nil {
+			return nil
+		}
+		return []interface{}{v}
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return walkStructured(v[keys[0]], keys[1:])
+	case []interface{}:
+		if i, err := strconv.Atoi(keys[0]); err == nil {
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return nil
+			}
+			return walkStructured(v[i], keys[1:])
+		}
+		var vals []interface{}
+		for _, c := range v {
+			vals = append(vals, walkStructured(c, keys)...)
+		}
+		return vals
+	}
+	return nil
+}
+
+// parseJSONLD 解析 <script type="application/ld+json">，展开数组和 @graph
+func (t *StructuredData) parseJSONLD(dom *goquery.Document) {
+	dom.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(s.Text())), &v); err != nil {
+			return
+		}
+		t.addJSONLD(v)
+	})
+}
+
+// addJSONLD 添加一个 JSON-LD 值，数组和 @graph 中的每一项作为一个数据块
+func (t *StructuredData) addJSONLD(v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, c := range v {
+			t.addJSONLD(c)
+		}
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			t.addJSONLD(graph)
+			return
+		}
+		normalizeJSONLD(v)
+		t.items = append(t.items, v)
+	}
+}
+
+// normalizeJSONLD 递归去掉 @type 的 schema.org 前缀，删除 @context
+func normalizeJSONLD(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		delete(v, "@context")
+		switch typ := v["@type"].(type) {
+		case string:
+			v["@type"] = schemaType(typ)
+		case []interface{}:
+			for i := range typ {
+				if s, ok := typ[i].(string); ok {
+					typ[i] = schemaType(s)
+				}
+			}
+		}
+		for _, c := range v {
+			normalizeJSONLD(c)
+		}
+	case []interface{}:
+		for _, c := range v {
+			normalizeJSONLD(c)
+		}
+	}
+}
+
+// schemaType 去掉类型的 schema.org 前缀
+func schemaType(s string) string {
+	for _, prefix := range []string{"http://schema.org/", "https://schema.org/", "schema:"} {
+		if strings.HasPrefix(s, prefix) {
+			return s[len(prefix):]
+		}
+	}
+	return s
+}
+
+// parseMicrodata 解析 itemscope 元素，嵌套在其他数据块属性中的 itemscope 作为属性值
+func (t *StructuredData) parseMicrodata(dom *goquery.Document) {
+	dom.Find("[itemscope]").Each(func(i int, s *goquery.Selection) {
+		if _, ok := s.Attr("itemprop"); ok && s.ParentsFiltered("[itemscope]").Length() > 0 {
+			return
+		}
+		t.items = append(t.items, microdataItem(s))
+	})
+}
+
+// microdataItem 解析一个 itemscope 元素，属性出现多次时为数组
+func microdataItem(s *goquery.Selection) map[string]interface{} {
+	item := make(map[string]interface{})
+	if typ, ok := s.Attr("itemtype"); ok {
+		var types []interface{}
+		for _, v := range strings.Fields(typ) {
+			types = append(types, schemaType(v))
+		}
+		if len(types) == 1 {
+			item["@type"] = types[0]
+		} else if len(types) > 1 {
+			item["@type"] = types
+		}
+	}
+	if id, ok := s.Attr("itemid"); ok {
+		item["@id"] = id
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			cs := goquery.NewDocumentFromNode(c).Selection
+			_, scope := cs.Attr("itemscope")
+			if props, ok := cs.Attr("itemprop"); ok {
+				var v interface{}
+				if scope {
+					v = microdataItem(cs)
+				} else {
+					v = microdataValue(cs)
+				}
+				for _, name := range strings.Fields(props) {
+					addStructuredValue(item, name, v)
+				}
+			}
+			// 嵌套的 itemscope 属于另一个数据块
+			if !scope {
+				walk(c)
+			}
+		}
+	}
+	walk(s.Get(0))
+	return item
+}
+
+// microdataValue 按元素类型取得属性值
+func microdataValue(s *goquery.Selection) string {
+	var attr string
+	switch goquery.NodeName(s) {
+	case "meta":
+		attr = "content"
+	case "a", "area", "link":
+		attr = "href"
+	case "img", "audio", "video", "source", "embed", "iframe", "track":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "time":
+		attr = "datetime"
+	case "data", "meter":
+		attr = "value"
+	}
+	if attr != "" {
+		if v, ok := s.Attr(attr); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	if v, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(v)
+	}
+	return normalizeSpace(s.Text())
+}
+
+// parseOpenGraph 解析 <meta property="og:..."> 等带命名空间的 meta 标签，
+// og: 前缀去掉，其他前缀(如 article:, product:)保留
+func (t *StructuredData) parseOpenGraph(dom *goquery.Document) {
+	item := make(map[string]interface{})
+	dom.Find("meta[property][content]").Each(func(i int, s *goquery.Selection) {
+		prop, _ := s.Attr("property")
+		if !strings.Contains(prop, ":") {
+			return
+		}
+		content, _ := s.Attr("content")
+		addStructuredValue(item, strings.TrimPrefix(prop, "og:"), strings.TrimSpace(content))
+	})
+	if len(item) == 0 {
+		return
+	}
+	item["@type"] = "OpenGraph"
+	t.items = append(t.items, item)
+}
+
+// addStructuredValue 添加属性值，重复的属性转为数组
+func addStructuredValue(item map[string]interface{}, name string, v interface{}) {
+	old, ok := item[name]
+	if !ok {
+		item[name] = v
+		return
+	}
+	if arr, ok := old.([]interface{}); ok {
+		item[name] = append(arr, v)
+		return
+	}
+	item[name] = []interface{}{old, v}
+}
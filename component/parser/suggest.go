@@ -0,0 +1,265 @@
+package parser
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Find 查找全部匹配及其位置，值同 MatchAll，Path 为节点的CSS路径
+func (t *HTMLDom) Find(rule string) []Match {
+	nodes, ex := t.selectNodes(rule)
+	var ms []Match
+	for _, node := range nodes {
+		v, ok := t.extract(node, ex)
+		if !ok {
+			continue
+		}
+		m := newMatch(t.body, v, t.NodeOffset(node))
+		m.Path = t.NodePath(node)
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// NodeOffset 返回节点的开始标签在原始内容中的字节偏移，无法确定时返回 -1
+// 按标签名在文档中的顺序对应原始内容的开始标签，解析器补全的标签(如 tbody)可能无法对应
+func (t *HTMLDom) NodeOffset(node *html.Node) int {
+	if node == nil || node.Type != html.ElementNode {
+		return -1
+	}
+	if t.offsets == nil {
+		t.offsets = nodeOffsets(t.body, t.dom.Nodes)
+	}
+	if v, ok := t.offsets[node]; ok {
+		return v
+	}
+	return -1
+}
+
+// nodeOffsets 使用分词器记录每个开始标签的偏移，再按顺序对应到DOM节点
+func nodeOffsets(body []byte, roots []*html.Node) map[*html.Node]int {
+	tags := make(map[string][]int)
+	z := html.NewTokenizer(bytes.NewReader(body))
+	offset := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := len(z.Raw())
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			name, _ := z.TagName()
+			tags[string(name)] = append(tags[string(name)], offset)
+		}
+		offset += raw
+	}
+
+	offsets := make(map[*html.Node]int)
+	seen := make(map[string]int)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			i := seen[n.Data]
+			if i < len(tags[n.Data]) {
+				offsets[n] = tags[n.Data][i]
+			}
+			seen[n.Data] = i + 1
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range roots {
+		walk(n)
+	}
+	return offsets
+}
+
+// NodePath 返回节点从根开始的完整CSS路径，如 html > body > ul > li:nth-child(2)
+func (t *HTMLDom) NodePath(node *html.Node) string {
+	var steps []string
+	for n := node; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		step := n.Data
+		if n.Parent != nil && n.Parent.Type == html.ElementNode {
+			step += ":nth-child(" + strconv.Itoa(childIndex(n)) + ")"
+		}
+		steps = append([]string{step}, steps...)
+	}
+	return strings.Join(steps, " > ")
+}
+
+// SuggestSelector 查找包含指定文本的元素，返回可以匹配到该元素的选择器，越稳定的越靠前
+// 优先使用 id，其次是最近的带 id 的祖先加上 标签.类名 组成的最短路径，最后是完整的 nth-child 路径
+func (t *HTMLDom) SuggestSelector(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	node := t.findTextNode(text)
+	if node == nil {
+		return nil
+	}
+
+	var sels []string
+	add := func(sel string) {
+		if sel == "" || !t.selects(sel, node) {
+			return
+		}
+		for _, s := range sels {
+			if s == sel {
+				return
+			}
+		}
+		sels = append(sels, sel)
+	}
+
+	// 节点本身有 id
+	if id := t.NodeAttr(node, "id"); isStableName(id) {
+		add("#" + id)
+	}
+
+	// 从节点向上构建路径，遇到带 id 的祖先停止
+	var steps []string
+	anchor := ""
+	for n := node; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		if n != node {
+			if id := t.NodeAttr(n, "id"); isStableName(id) {
+				anchor = "#" + id
+				break
+			}
+		}
+		steps = append([]string{nodeStep(t, n)}, steps...)
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		sel := strings.Join(steps[i:], " > ")
+		if anchor != "" {
+			sel = anchor + " " + sel
+		}
+		if t.unique(sel) {
+			add(sel)
+			break
+		}
+	}
+	// 不唯一时，在路径中的一级加上序号区分
+	for i := len(steps) - 1; i >= 0; i-- {
+		n := node
+		for j := len(steps) - 1; j > i; j-- {
+			n = n.Parent
+		}
+		indexed := make([]string, len(steps))
+		copy(indexed, steps)
+		indexed[i] += ":nth-child(" + strconv.Itoa(childIndex(n)) + ")"
+		sel := strings.Join(indexed, " > ")
+		if anchor != "" {
+			sel = anchor + " > " + sel
+		}
+		if t.unique(sel) {
+			add(sel)
+			break
+		}
+	}
+	// 仍不唯一时，只要第一个匹配到的是该节点，Match 也能使用
+	for i := len(steps) - 1; i >= 0; i-- {
+		sel := strings.Join(steps[i:], " > ")
+		if anchor != "" {
+			sel = anchor + " " + sel
+		}
+		if t.selects(sel, node) {
+			add(sel)
+			break
+		}
+	}
+	add(t.NodePath(node))
+	return sels
+}
+
+// findTextNode 查找文本包含指定内容的最深的元素，有多个时取文档中的第一个
+func (t *HTMLDom) findTextNode(text string) *html.Node {
+	text = normalizeSpace(text)
+	for _, n := range t.dom.Nodes {
+		if found := t.deepest(n, text); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// deepest 递归查找包含文本的最深元素
+func (t *HTMLDom) deepest(n *html.Node, text string) *html.Node {
+	if !strings.Contains(normalizeSpace(t.GetNodeText(n)), text) {
+		return nil
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if found := t.deepest(c, text); found != nil {
+			return found
+		}
+	}
+	if n.Type == html.ElementNode {
+		return n
+	}
+	return nil
+}
+
+// selects 判断选择器的第一个匹配是否为该节点
+func (t *HTMLDom) selects(sel string, node *html.Node) bool {
+	dom := t.safeFind(sel)
+	return dom != nil && dom.Size() > 0 && dom.Get(0) == node
+}
+
+// unique 判断选择器是否只匹配到一个节点
+func (t *HTMLDom) unique(sel string) bool {
+	dom := t.safeFind(sel)
+	return dom != nil && dom.Size() == 1
+}
+
+// safeFind 查找选择器，非法的选择器返回 nil 而不是 panic
+func (t *HTMLDom) safeFind(sel string) (dom *goquery.Selection) {
+	defer func() {
+		if recover() != nil {
+			dom = nil
+		}
+	}()
+	return t.DomFind(sel)
+}
+
+// nodeStep 返回节点的 标签.类名 形式，忽略看起来是自动生成的类名
+func nodeStep(t *HTMLDom, n *html.Node) string {
+	step := n.Data
+	for _, c := range strings.Fields(t.NodeAttr(n, "class")) {
+		if isStableName(c) {
+			step += "." + c
+		}
+	}
+	return step
+}
+
+// childIndex 返回节点在父节点的元素子节点中的序号，从1开始
+func childIndex(n *html.Node) int {
+	i := 1
+	for c := n.PrevSibling; c != nil; c = c.PrevSibling {
+		if c.Type == html.ElementNode {
+			i++
+		}
+	}
+	return i
+}
+
+var reGeneratedName = regexp.MustCompile(`\d{3,}|^[a-zA-Z]{1,3}-[a-zA-Z]*[0-9][a-zA-Z0-9]*$|[^a-zA-Z0-9_-]`)
+
+// isStableName 判断 id 或 class 是否稳定，包含长数字或者哈希后缀的视为自动生成
+func isStableName(s string) bool {
+	return s != "" && !reGeneratedName.MatchString(s)
+}
+
+// normalizeSpace 合并连续空白
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Table 表格解析器，支持 CSV 和 XLSX，第一个非空行为表头，数据按列名访问
+//
+// 规则：
+//
+//	价格         第一个工作表中列名为 价格 的列
+//	Sheet2!价格  指定工作表的列
+//	C            没有该列名时，按列的字母访问
+//	*            整行，Find 返回每行的JSON，字段中使用时每列作为一个子字段
+//	Sheet2!*     指定工作表的整行
+type Table struct {
+	sheets []*Sheet // 工作表，CSV 只有一个没有名字的工作表
+}
+
+// Sheet 一个工作表
+type Sheet struct {
+	Name   string     // 工作表名称
+	Header []string   // 表头，空的列名使用列的字母，重复的列名加上序号
+	Rows   [][]string // 数据行，每行的列数与表头相同
+}
+
+// NewTable 创建一个表格解析器，按内容判断是 XLSX(zip) 还是 CSV
+func NewTable(body []byte) (*Table, error) {
+	if bytes.HasPrefix(body, []byte("PK\x03\x04")) {
+		return NewXLSX(body)
+	}
+	return NewCSV(body)
+}
+
+// NewCSV 创建一个 CSV 表格解析器，自动识别逗号，分号，制表符和竖线分隔符
+func NewCSV(body []byte) (*Table, error) {
+	if body == nil {
+		return nil, Errorf("body is empty")
+	}
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comma = csvDelimiter(body)
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	t := new(Table)
+	t.sheets = append(t.sheets, newSheet("", records))
+	return t, nil
+}
+
+// csvDelimiter 根据第一行中引号之外出现最多的字符判断分隔符
+func csvDelimiter(body []byte) rune {
+	line := body
+	if p := bytes.IndexByte(body, '\n'); p >= 0 {
+		line = body[:p]
+	}
+	counts := make(map[rune]int)
+	quoted := false
+	for _, c := range string(line) {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ',' || c == ';' || c == '\t' || c == '|'):
+			counts[c]++
+		}
+	}
+	delim := ','
+	for _, c := range []rune{';', '\t', '|'} {
+		if counts[c] > counts[delim] {
+			delim = c
+		}
+	}
+	return delim
+}
+
+// newSheet 由全部记录创建工作表，跳过开头的空行，补齐每行的列数
+func newSheet(name string, records [][]string) *Sheet {
+	s := &Sheet{Name: name}
+	for len(records) > 0 && isBlankRecord(records[0]) {
+		records = records[1:]
+	}
+	if len(records) == 0 {
+		return s
+	}
+	width := 0
+	for _, rec := range records {
+		if len(rec) > width {
+			width = len(rec)
+		}
+	}
+	seen := make(map[string]int)
+	for i := 0; i < width; i++ {
+		name := ""
+		if i < len(records[0]) {
+			name = strings.TrimSpace(records[0][i])
+		}
+		if name == "" {
+			name = columnName(i)
+		}
+		if n := seen[name]; n > 0 {
+			seen[name]++
+			name += "_" + strconv.Itoa(n+1)
+		} else {
+			seen[name] = 1
+		}
+		s.Header = append(s.Header, name)
+	}
+	for _, rec := range records[1:] {
+		if isBlankRecord(rec) {
+			continue
+		}
+		row := make([]string, width)
+		copy(row, rec)
+		s.Rows = append(s.Rows, row)
+	}
+	return s
+}
+
+// isBlankRecord 是否为空行
+func isBlankRecord(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// columnName 列序号对应的字母，从0开始，0 为 A，26 为 AA
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// columnIndex 列的字母对应的序号，不是字母时返回 -1
+func columnIndex(name string) int {
+	if name == "" {
+		return -1
+	}
+	i := 0
+	for _, c := range name {
+		if c < 'A' || c > 'Z' {
+			return -1
+		}
+		i = i*26 + int(c-'A') + 1
+	}
+	return i - 1
+}
+
+// Sheets 返回全部工作表
+func (t *Table) Sheets() []*Sheet {
+	return t.sheets
+}
+
+// Sheet 返回指定名称的工作表，名称为空时返回第一个工作表
+func (t *Table) Sheet(name string) *Sheet {
+	for _, s := range t.sheets {
+		if name == "" || s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Column 返回列名对应的序号，没有该列名时按列的字母查找，找不到返回 -1
+func (s *Sheet) Column(name string) int {
+	for i, h := range s.Header {
+		if h == name {
+			return i
+		}
+	}
+	if i := columnIndex(name); i >= 0 && i < len(s.Header) {
+		return i
+	}
+	return -1
+}
+
+// Map 将一行转为以列名为键的数据
+func (s *Sheet) Map(row []string) map[string]interface{} {
+	m := make(map[string]interface{}, len(s.Header))
+	for i, h := range s.Header {
+		m[h] = row[i]
+	}
+	return m
+}
+
+// ParseTableRule 拆分规则为工作表名称和列名
+func ParseTableRule(rule string) (sheet, column string) {
+	if p := strings.LastIndex(rule, "!"); p >= 0 {
+		return rule[:p], rule[p+1:]
+	}
+	return "", rule
+}
+
+// Match 配置规则，返回第一行中该列的值，规则为整行时返回第一行的JSON
+func (t *Table) Match(rule string) string {
+	for _, m := range t.Find(rule) {
+		return m.Value
+	}
+	return ""
+}
+
+// MatchAll 配置规则，返回每一行中该列的值，规则为整行时返回每一行的JSON
+func (t *Table) MatchAll(rule string) []string {
+	var vals []string
+	for _, m := range t.Find(rule) {
+		vals = append(vals, m.Value)
+	}
+	return vals
+}
+
+// Find 查找全部匹配，Path 为单元格位置，如 Sheet1!B2，整行时为行号，行号以表头为第1行，不计空行
+func (t *Table) Find(rule string) []Match {
+	name, column := ParseTableRule(rule)
+	s := t.Sheet(name)
+	if s == nil || column == "" {
+		return nil
+	}
+	prefix := ""
+	if s.Name != "" {
+		prefix = s.Name + "!"
+	}
+	var ms []Match
+	if column == "*" {
+		for i, row := range s.Rows {
+			b, _ := json.Marshal(s.Map(row))
+			m := newMatch(nil, string(b), -1)
+			m.Path = prefix + strconv.Itoa(i+2)
+			ms = append(ms, m)
+		}
+		return ms
+	}
+	col := s.Column(column)
+	if col < 0 {
+		return nil
+	}
+	for i, row := range s.Rows {
+		m := newMatch(nil, row[col], -1)
+		m.Path = prefix + columnName(col) + strconv.Itoa(i+2)
+		ms = append(ms, m)
+	}
+	return ms
+}
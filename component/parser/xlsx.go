@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// xlsx 文件中用到的部分结构
+type (
+	xlsxWorkbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	xlsxRels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	xlsxText struct {
+		T string `xml:"t"`
+		R []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	}
+	xlsxSST struct {
+		SI []xlsxText `xml:"si"`
+	}
+	xlsxWorksheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref  string   `xml:"r,attr"`
+				Type string   `xml:"t,attr"`
+				V    string   `xml:"v"`
+				IS   xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+)
+
+// String 返回富文本的全部文本
+func (x xlsxText) String() string {
+	if len(x.R) == 0 {
+		return x.T
+	}
+	var b strings.Builder
+	for _, r := range x.R {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+// NewXLSX 创建一个 XLSX 表格解析器，每个工作表为一个 Sheet，
+// 单元格只读取值，不处理公式和格式，日期为 Excel 的序列数
+func NewXLSX(body []byte) (*Table, error) {
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	var wb xlsxWorkbook
+	if err := xlsxDecode(files, "xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	var rels xlsxRels
+	if err := xlsxDecode(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for _, r := range rels.Rels {
+		if strings.HasPrefix(r.Target, "/") {
+			targets[r.ID] = strings.TrimPrefix(r.Target, "/")
+		} else {
+			targets[r.ID] = path.Join("xl", r.Target)
+		}
+	}
+	var sst xlsxSST
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := xlsxDecode(files, "xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+	}
+	t := new(Table)
+	for _, s := range wb.Sheets {
+		var ws xlsxWorksheet
+		if err := xlsxDecode(files, targets[s.RID], &ws); err != nil {
+			return nil, err
+		}
+		var records [][]string
+		for _, row := range ws.Rows {
+			var rec []string
+			for _, c := range row.Cells {
+				col := len(rec)
+				if c.Ref != "" {
+					col = columnIndex(strings.TrimRight(c.Ref, "0123456789"))
+				}
+				if col < 0 {
+					continue
+				}
+				for len(rec) <= col {
+					rec = append(rec, "")
+				}
+				switch c.Type {
+				case "s":
+					if i := atoi(c.V); i >= 0 && i < len(sst.SI) {
+						rec[col] = sst.SI[i].String()
+					}
+				case "inlineStr":
+					rec[col] = c.IS.String()
+				case "b":
+					if c.V == "1" {
+						rec[col] = "true"
+					} else {
+						rec[col] = "false"
+					}
+				default:
+					rec[col] = c.V
+				}
+			}
+			records = append(records, rec)
+		}
+		t.sheets = append(t.sheets, newSheet(s.Name, records))
+	}
+	if len(t.sheets) == 0 {
+		return nil, Errorf("xlsx has no sheet")
+	}
+	return t, nil
+}
+
+// xlsxDecode 解码压缩包中的 XML 文件
+func xlsxDecode(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return Errorf("xlsx: %s not found", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// atoi 转换非负整数，失败返回 -1
+func atoi(s string) int {
+	if s == "" {
+		return -1
+	}
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return -1
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
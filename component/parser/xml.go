@@ -0,0 +1,255 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/safeie/spider/common/log"
+	"golang.org/x/net/html/charset"
+)
+
+// XMLNamespaces 常用的命名空间前缀，文档中声明了这些命名空间时，
+// 规则中可以使用这里的前缀，不论文档中使用的前缀是什么(包括默认命名空间)
+var XMLNamespaces = map[string]string{
+	"atom":    "http://www.w3.org/2005/Atom",
+	"rdf":     "http://www.w3.org/1999/02/22-rdf-syntax-ns#",
+	"rss":     "http://purl.org/rss/1.0/",
+	"dc":      "http://purl.org/dc/elements/1.1/",
+	"content": "http://purl.org/rss/1.0/modules/content/",
+	"media":   "http://search.yahoo.com/mrss/",
+	"itunes":  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+	"sitemap": "http://www.sitemaps.org/schemas/sitemap/0.9",
+}
+
+// XML XML 解析器，使用 XPath 规则，支持命名空间
+//
+// 规则中的前缀与文档中的前缀相同时直接匹配，也可以使用 XMLNamespaces 中的前缀按命名空间匹配，
+// 如 Atom 文档使用默认命名空间时，//entry 和 //atom:entry 都可以匹配到 entry 元素
+//
+// 匹配到只包含文本的元素返回文本(CDATA 和实体已解码)，包含子元素时返回 innerXML，
+// 匹配到属性返回属性值，表达式的结果是字符串，数字或布尔值时返回其字符串形式
+type XML struct {
+	body       []byte            // 原始内容
+	doc        *xmlquery.Node    // XML Dom结构
+	namespaces map[string]string // 规则中可以使用的命名空间前缀
+}
+
+// NewXML 创建一个 XML 解析器，不要求严格的格式，未声明的前缀和HTML实体都可以解析，
+// 未声明的前缀(如从订阅中截取的条目)按前缀匹配，XMLNamespaces 中的前缀同时设置其命名空间
+func NewXML(body []byte) (*XML, error) {
+	if body == nil {
+		return nil, Errorf("body is empty")
+	}
+	var err error
+	t := new(XML)
+	t.body = body
+	t.doc, err = xmlquery.ParseWithOptions(bytes.NewReader(body), xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{
+			Strict:        false,
+			Entity:        xml.HTMLEntity,
+			CharsetReader: charset.NewReaderLabel,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.namespaces = xmlNamespaces(t.doc)
+	return t, nil
+}
+
+// Doc 返回 XML Dom结构
+func (t *XML) Doc() *xmlquery.Node {
+	return t.doc
+}
+
+// Match 配置规则，返回匹配到的值
+func (t *XML) Match(rule string) string {
+	nodes := t.evaluate(rule, 1)
+	if len(nodes) == 0 {
+		return ""
+	}
+	return nodes[0].value
+}
+
+// MatchAll 配置规则，返回匹配到的值，复数
+func (t *XML) MatchAll(rule string) []string {
+	var vals []string
+	for _, n := range t.evaluate(rule, -1) {
+		vals = append(vals, n.value)
+	}
+	return vals
+}
+
+// Find 查找全部匹配，XML 节点不记录位置，Path 为节点的 XPath 路径
+func (t *XML) Find(rule string) []Match {
+	var ms []Match
+	for _, n := range t.evaluate(rule, -1) {
+		m := newMatch(t.body, n.value, -1)
+		if n.node != nil {
+			m.Path = xmlNodePath(n.node, n.attr)
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// xmlNode XPath 匹配到的一个 XML 节点
+type xmlNode struct {
+	node  *xmlquery.Node // 节点，属性节点时为属性所在的元素
+	attr  string         // 属性名，非属性节点为空
+	value string         // 节点的值
+}
+
+// Select 返回规则匹配到的元素，用于进一步处理
+func (t *XML) Select(rule string) []*xmlquery.Node {
+	var nodes []*xmlquery.Node
+	for _, n := range t.evaluate(rule, -1) {
+		if n.node != nil && n.attr == "" {
+			nodes = append(nodes, n.node)
+		}
+	}
+	return nodes
+}
+
+// evaluate 执行表达式，返回最多 limit 个节点，limit < 0 不限制
+func (t *XML) evaluate(rule string, limit int) []xmlNode {
+	if rule == "" {
+		return nil
+	}
+	e := compileXPath(rule, t.namespaces)
+	if e.err != nil {
+		e.logged.Do(func() {
+			log.Errorf("XML XPath Compile Error: %s %v\n", rule, e.err)
+		})
+		return nil
+	}
+	e.mu.Lock()
+	result := e.expr.Evaluate(xmlquery.CreateXPathNavigator(t.doc))
+	e.mu.Unlock()
+	var nodes []xmlNode
+	switch v := result.(type) {
+	case *xpath.NodeIterator:
+		for v.MoveNext() && (limit < 0 || len(nodes) < limit) {
+			nav := v.Current().(*xmlquery.NodeNavigator)
+			node := nav.Current()
+			n := xmlNode{node: node}
+			switch nav.NodeType() {
+			case xpath.AttributeNode:
+				n.attr = nav.LocalName()
+				if p := nav.Prefix(); p != "" {
+					n.attr = p + ":" + n.attr
+				}
+				n.value = nav.Value()
+			case xpath.ElementNode:
+				n.value = xmlValue(node)
+			case xpath.RootNode:
+				n.node = nil
+				n.value = node.OutputXML(false)
+			default:
+				n.value = nav.Value()
+			}
+			nodes = append(nodes, n)
+		}
+	case string:
+		nodes = append(nodes, xmlNode{value: v})
+	case float64:
+		nodes = append(nodes, xmlNode{value: strconv.FormatFloat(v, 'f', -1, 64)})
+	case bool:
+		nodes = append(nodes, xmlNode{value: strconv.FormatBool(v)})
+	}
+	return nodes
+}
+
+// xmlValue 只包含文本的元素返回文本，否则返回 innerXML
+func xmlValue(node *xmlquery.Node) string {
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xmlquery.ElementNode {
+			return node.OutputXML(false)
+		}
+	}
+	return node.InnerText()
+}
+
+// xmlNamespaces 规则中可以使用的命名空间：文档中声明的前缀，XMLNamespaces 中在文档里声明过的命名空间，
+// 以及未声明的前缀；同时修复未声明前缀的元素，解析器把前缀当作了命名空间
+// 编译表达式时，使用了不在其中的前缀会出错，没有任何命名空间时返回 nil，按前缀的字面匹配
+func xmlNamespaces(doc *xmlquery.Node) map[string]string {
+	declared := make(map[string]string) // uri => prefix
+	prefixes := make(map[string]bool)
+	var elems []*xmlquery.Node
+	var walk func(n *xmlquery.Node)
+	walk = func(n *xmlquery.Node) {
+		if n.Type == xmlquery.ElementNode {
+			elems = append(elems, n)
+			for _, a := range n.Attr {
+				if a.Name.Local == "xmlns" && a.Name.Space == "" {
+					declared[a.Value] = ""
+				} else if a.Name.Space == "xmlns" {
+					declared[a.Value] = a.Name.Local
+					prefixes[a.Name.Local] = true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	ns := make(map[string]string)
+	for uri, prefix := range declared {
+		if prefix != "" {
+			ns[prefix] = uri
+		}
+	}
+	for _, n := range elems {
+		if _, ok := declared[n.NamespaceURI]; n.Prefix == "" && n.NamespaceURI != "" && !ok {
+			n.Prefix = n.NamespaceURI
+			if uri, ok := XMLNamespaces[n.Prefix]; ok {
+				n.NamespaceURI = uri
+			}
+			ns[n.Prefix] = n.NamespaceURI
+		}
+	}
+	for prefix, uri := range XMLNamespaces {
+		if p, ok := declared[uri]; ok && (p == prefix || !prefixes[prefix]) {
+			ns[prefix] = uri
+		}
+	}
+	if len(ns) == 0 {
+		return nil
+	}
+	return ns
+}
+
+// xmlNodePath 返回节点从根开始的 XPath 路径，如 /rss[1]/channel[1]/item[2]/title[1]
+func xmlNodePath(node *xmlquery.Node, attr string) string {
+	var steps []string
+	for n := node; n != nil && n.Parent != nil; n = n.Parent {
+		switch n.Type {
+		case xmlquery.ElementNode:
+			name := n.Data
+			if n.Prefix != "" {
+				name = n.Prefix + ":" + name
+			}
+			i := 1
+			for c := n.PrevSibling; c != nil; c = c.PrevSibling {
+				if c.Type == xmlquery.ElementNode && c.Data == n.Data && c.Prefix == n.Prefix {
+					i++
+				}
+			}
+			steps = append([]string{name + "[" + strconv.Itoa(i) + "]"}, steps...)
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			steps = append([]string{"text()"}, steps...)
+		case xmlquery.CommentNode:
+			steps = append([]string{"comment()"}, steps...)
+		}
+	}
+	if attr != "" {
+		steps = append(steps, "@"+attr)
+	}
+	return "/" + strings.Join(steps, "/")
+}
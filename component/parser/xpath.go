@@ -0,0 +1,226 @@
+package parser
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/safeie/spider/common/log"
+	"golang.org/x/net/html"
+)
+
+// XPath HTML XPath 解析器，支持 XPath 1.0 的函数和轴
+//
+// 匹配到元素返回 innerHTML，匹配到属性返回属性值，匹配到文本返回文本，
+// 表达式的结果是字符串，数字或布尔值时(如 count(//li), string(//a/@href))返回其字符串形式
+type XPath struct {
+	body    []byte             // 原始内容
+	doc     *html.Node         // HTML Dom结构
+	offsets map[*html.Node]int // 节点在原始内容中的偏移，查找位置时初始化
+}
+
+// xpathNode XPath 匹配到的一个节点
+type xpathNode struct {
+	node  *html.Node // 节点，属性节点时为属性所在的元素
+	attr  string     // 属性名，非属性节点为空
+	value string     // 节点的值
+}
+
+// xpathExpr 编译过的表达式，表达式执行时会修改内部状态，不能并发执行
+type xpathExpr struct {
+	expr   *xpath.Expr
+	err    error
+	logged sync.Once // 编译错误只记录一次日志
+	mu     sync.Mutex
+}
+
+// xpathCache 编译过的表达式，键为表达式和命名空间，规则来自配置，数量有限
+var xpathCache = struct {
+	exprs map[string]*xpathExpr
+	mu    sync.RWMutex
+}{exprs: make(map[string]*xpathExpr)}
+
+// compileXPath 编译表达式，ns 为规则中可以使用的命名空间前缀，结果缓存
+func compileXPath(rule string, ns map[string]string) *xpathExpr {
+	key := xpathKey(rule, ns)
+	xpathCache.mu.RLock()
+	e, ok := xpathCache.exprs[key]
+	xpathCache.mu.RUnlock()
+	if ok {
+		return e
+	}
+	e = new(xpathExpr)
+	e.expr, e.err = xpath.CompileWithNS(rule, ns)
+	xpathCache.mu.Lock()
+	if v, ok := xpathCache.exprs[key]; ok {
+		e = v
+	} else {
+		xpathCache.exprs[key] = e
+	}
+	xpathCache.mu.Unlock()
+	return e
+}
+
+// xpathKey 返回表达式的缓存键，命名空间按前缀排序附加在表达式后面
+func xpathKey(rule string, ns map[string]string) string {
+	if len(ns) == 0 {
+		return rule
+	}
+	prefixes := make([]string, 0, len(ns))
+	for prefix := range ns {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	var b strings.Builder
+	b.WriteString(rule)
+	for _, prefix := range prefixes {
+		b.WriteString("\x00" + prefix + "=" + ns[prefix])
+	}
+	return b.String()
+}
+
+// CompileXPath 检查 XPath 表达式，返回编译错误
+func CompileXPath(rule string) error {
+	return compileXPath(rule, nil).err
+}
+
+// NewXPath 创建一个 XPath 解析器
+func NewXPath(body []byte) (*XPath, error) {
+	if body == nil {
+		return nil, Errorf("body is empty")
+	}
+	var err error
+	t := new(XPath)
+	t.body = body
+	t.doc, err = htmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Match 配置规则，返回匹配到的值
+func (t *XPath) Match(rule string) string {
+	nodes := t.evaluate(rule, 1)
+	if len(nodes) == 0 {
+		return ""
+	}
+	return nodes[0].value
+}
+
+// MatchAll 配置规则，返回匹配到的值，复数
+func (t *XPath) MatchAll(rule string) []string {
+	var vals []string
+	for _, n := range t.evaluate(rule, -1) {
+		vals = append(vals, n.value)
+	}
+	return vals
+}
+
+// Find 查找全部匹配及其位置，Path 为节点的 XPath 路径
+func (t *XPath) Find(rule string) []Match {
+	var ms []Match
+	for _, n := range t.evaluate(rule, -1) {
+		offset := -1
+		if n.node != nil {
+			if t.offsets == nil {
+				t.offsets = nodeOffsets(t.body, []*html.Node{t.doc})
+			}
+			if v, ok := t.offsets[n.node]; ok {
+				offset = v
+			}
+		}
+		m := newMatch(t.body, n.value, offset)
+		if n.node != nil {
+			m.Path = xpathNodePath(n.node, n.attr)
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// evaluate 执行表达式，返回最多 limit 个节点，limit < 0 不限制
+func (t *XPath) evaluate(rule string, limit int) []xpathNode {
+	if rule == "" {
+		return nil
+	}
+	e := compileXPath(rule, nil)
+	if e.err != nil {
+		e.logged.Do(func() {
+			log.Errorf("XPath Compile Error: %s %v\n", rule, e.err)
+		})
+		return nil
+	}
+	e.mu.Lock()
+	result := e.expr.Evaluate(htmlquery.CreateXPathNavigator(t.doc))
+	e.mu.Unlock()
+	var nodes []xpathNode
+	switch v := result.(type) {
+	case *xpath.NodeIterator:
+		for v.MoveNext() && (limit < 0 || len(nodes) < limit) {
+			nav := v.Current().(*htmlquery.NodeNavigator)
+			node := nav.Current()
+			n := xpathNode{node: node}
+			switch nav.NodeType() {
+			case xpath.AttributeNode:
+				n.attr = nav.LocalName()
+				n.value = nav.Value()
+			case xpath.ElementNode:
+				n.value = innerHTML(node)
+			case xpath.RootNode:
+				n.node = nil
+				n.value = innerHTML(node)
+			default:
+				n.value = nav.Value()
+			}
+			nodes = append(nodes, n)
+		}
+	case string:
+		nodes = append(nodes, xpathNode{value: v})
+	case float64:
+		nodes = append(nodes, xpathNode{value: strconv.FormatFloat(v, 'f', -1, 64)})
+	case bool:
+		nodes = append(nodes, xpathNode{value: strconv.FormatBool(v)})
+	}
+	return nodes
+}
+
+// innerHTML 返回节点的 innerHTML
+func innerHTML(node *html.Node) string {
+	var buf bytes.Buffer
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return ""
+		}
+	}
+	return buf.String()
+}
+
+// xpathNodePath 返回节点从根开始的 XPath 路径，如 /html/body/ul/li[2]/@class
+func xpathNodePath(node *html.Node, attr string) string {
+	var steps []string
+	for n := node; n != nil && n.Parent != nil; n = n.Parent {
+		switch n.Type {
+		case html.ElementNode:
+			i := 1
+			for c := n.PrevSibling; c != nil; c = c.PrevSibling {
+				if c.Type == html.ElementNode && c.Data == n.Data {
+					i++
+				}
+			}
+			steps = append([]string{n.Data + "[" + strconv.Itoa(i) + "]"}, steps...)
+		case html.TextNode:
+			steps = append([]string{"text()"}, steps...)
+		case html.CommentNode:
+			steps = append([]string{"comment()"}, steps...)
+		}
+	}
+	if attr != "" {
+		steps = append(steps, "@"+attr)
+	}
+	return "/" + strings.Join(steps, "/")
+}
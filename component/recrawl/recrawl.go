@@ -0,0 +1,233 @@
+// Package recrawl 提供增量重新抓取的调度，记录每个URL的抓取时间、内容摘要和变化历史，
+// 按内容变化的频率调整每个URL的重新抓取间隔，变化越频繁间隔越短
+package recrawl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInterval 第一次抓取后的重新抓取间隔
+	DefaultInterval = time.Hour
+	// DefaultMinInterval 最短的重新抓取间隔
+	DefaultMinInterval = 5 * time.Minute
+	// DefaultMaxInterval 最长的重新抓取间隔
+	DefaultMaxInterval = 7 * 24 * time.Hour
+	// DefaultHistorySize 每个URL保留的变化记录数量
+	DefaultHistorySize = 10
+)
+
+// Record 一个URL的抓取记录
+type Record struct {
+	URL       string        `json:"url"`        // 抓取的URL
+	Hash      string        `json:"hash"`       // 最近一次抓取的内容摘要
+	LastFetch time.Time     `json:"last_fetch"` // 最近一次抓取的时间
+	NextFetch time.Time     `json:"next_fetch"` // 下一次抓取的时间
+	Interval  time.Duration `json:"interval"`   // 当前的重新抓取间隔
+	Fetches   int           `json:"fetches"`    // 抓取次数
+	Changes   int           `json:"changes"`    // 内容变化的次数，不含第一次抓取
+	History   []Change      `json:"history"`    // 最近的变化记录，含第一次抓取
+}
+
+// Change 一次内容变化
+type Change struct {
+	Time time.Time `json:"time"` // 发现变化的时间
+	Hash string    `json:"hash"` // 变化后的内容摘要
+}
+
+// Scheduler 重新抓取调度器，内容变化时间隔减半，没有变化时间隔增加一半，限制在最短和最长间隔之间
+// 设置了文件时，打开时加载，Sync 和 Close 时保存
+type Scheduler struct {
+	file        string             // 保存的文件，为空不保存
+	interval    time.Duration      // 第一次抓取后的间隔
+	minInterval time.Duration      // 最短间隔
+	maxInterval time.Duration      // 最长间隔
+	historySize int                // 保留的变化记录数量
+	records     map[string]*Record // 抓取记录，键为规范化的URL
+	rw          sync.RWMutex
+}
+
+// NewScheduler 创建一个重新抓取调度器，使用默认的间隔
+func NewScheduler() *Scheduler {
+	s := new(Scheduler)
+	s.interval = DefaultInterval
+	s.minInterval = DefaultMinInterval
+	s.maxInterval = DefaultMaxInterval
+	s.historySize = DefaultHistorySize
+	s.records = make(map[string]*Record)
+	return s
+}
+
+// OpenScheduler 打开保存在文件中的调度器，文件不存在时创建新的调度器
+func OpenScheduler(file string) (*Scheduler, error) {
+	s := NewScheduler()
+	s.file = file
+	body, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(body, &s.records); err != nil {
+		return nil, err
+	}
+	if s.records == nil {
+		s.records = make(map[string]*Record)
+	}
+	return s, nil
+}
+
+// SetInterval 设置第一次抓取后的重新抓取间隔
+func (s *Scheduler) SetInterval(d time.Duration) *Scheduler {
+	if d > 0 {
+		s.interval = d
+	}
+	return s
+}
+
+// SetIntervalRange 设置最短和最长的重新抓取间隔，0 不修改
+func (s *Scheduler) SetIntervalRange(min, max time.Duration) *Scheduler {
+	if min > 0 {
+		s.minInterval = min
+	}
+	if max > 0 {
+		s.maxInterval = max
+	}
+	if s.maxInterval < s.minInterval {
+		s.maxInterval = s.minInterval
+	}
+	return s
+}
+
+// SetHistorySize 设置每个URL保留的变化记录数量
+func (s *Scheduler) SetHistorySize(n int) *Scheduler {
+	if n > 0 {
+		s.historySize = n
+	}
+	return s
+}
+
+// clamp 将间隔限制在最短和最长间隔之间
+func (s *Scheduler) clamp(d time.Duration) time.Duration {
+	if d < s.minInterval {
+		return s.minInterval
+	}
+	if d > s.maxInterval {
+		return s.maxInterval
+	}
+	return d
+}
+
+// Update 记录一次抓取，key 为规范化的URL，hash 为内容摘要，返回内容是否变化，第一次抓取算变化
+func (s *Scheduler) Update(key, url, hash string, now time.Time) bool {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	r, ok := s.records[key]
+	if !ok {
+		r = &Record{URL: url, Interval: s.clamp(s.interval)}
+		s.records[key] = r
+	}
+	changed := !ok || r.Hash != hash
+	if ok {
+		if changed {
+			r.Interval = s.clamp(r.Interval / 2)
+			r.Changes++
+		} else {
+			r.Interval = s.clamp(r.Interval + r.Interval/2)
+		}
+	}
+	if changed {
+		r.Hash = hash
+		r.History = append(r.History, Change{Time: now, Hash: hash})
+		if len(r.History) > s.historySize {
+			r.History = r.History[len(r.History)-s.historySize:]
+		}
+	}
+	r.URL = url
+	r.LastFetch = now
+	r.NextFetch = now.Add(r.Interval)
+	r.Fetches++
+	return changed
+}
+
+// Due 返回到期需要重新抓取的URL，最早到期的在前，最多 limit 个，limit 不大于0时不限制
+// 返回的URL的下一次抓取时间推迟一个间隔，抓取失败时到期后再次返回
+func (s *Scheduler) Due(now time.Time, limit int) []string {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	var due []*Record
+	for _, r := range s.records {
+		if !r.NextFetch.After(now) {
+			due = append(due, r)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextFetch.Before(due[j].NextFetch)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	urls := make([]string, 0, len(due))
+	for _, r := range due {
+		r.NextFetch = now.Add(r.Interval)
+		urls = append(urls, r.URL)
+	}
+	return urls
+}
+
+// Get 返回一个URL的抓取记录
+func (s *Scheduler) Get(key string) (Record, bool) {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	r, ok := s.records[key]
+	if !ok {
+		return Record{}, false
+	}
+	c := *r
+	c.History = append([]Change(nil), r.History...)
+	return c, true
+}
+
+// Remove 删除一个URL的抓取记录，不再重新抓取
+func (s *Scheduler) Remove(key string) {
+	s.rw.Lock()
+	delete(s.records, key)
+	s.rw.Unlock()
+}
+
+// Len 返回记录的URL数量
+func (s *Scheduler) Len() int {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	return len(s.records)
+}
+
+// Sync 保存到文件，没有设置文件时不处理
+func (s *Scheduler) Sync() error {
+	if s.file == "" {
+		return nil
+	}
+	s.rw.RLock()
+	body, err := json.Marshal(s.records)
+	s.rw.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err = ioutil.WriteFile(tmp, body, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// Close 保存到文件
+func (s *Scheduler) Close() error {
+	return s.Sync()
+}
@@ -0,0 +1,212 @@
+// Package schedule 在一个常驻进程中按 cron 表达式或固定间隔定时执行多个任务
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/safeie/spider/common/cron"
+	"github.com/safeie/spider/common/log"
+	"github.com/safeie/spider/component/task"
+)
+
+// Status 任务的执行状态
+type Status struct {
+	ID        string    `json:"id"`         // 任务编号
+	Name      string    `json:"name"`       // 任务名称
+	Spec      string    `json:"spec"`       // 执行计划
+	Running   bool      `json:"running"`    // 是否在执行中
+	Runs      int       `json:"runs"`       // 执行的次数
+	Skipped   int       `json:"skipped"`    // 到期时上一次执行还没有结束，跳过的次数
+	LastStart time.Time `json:"last_start"` // 最近一次开始的时间
+	LastEnd   time.Time `json:"last_end"`   // 最近一次结束的时间
+	LastError string    `json:"last_error"` // 最近一次执行的错误，成功时为空
+	Next      time.Time `json:"next"`       // 下一次执行的时间
+}
+
+// entry 一个定时执行的任务
+type entry struct {
+	task     *task.Task
+	schedule cron.Schedule
+	status   Status
+}
+
+// Scheduler 定时执行任务，同一个任务上一次执行没有结束时跳过本次执行，再次执行前调用 Task.Reset 清空本次运行抓取过的URL
+// 保存到文件的判断重复存储和抓取记录不清空，每次执行只抓取新的URL；每秒检查一次到期的任务
+type Scheduler struct {
+	entries map[string]*entry // 任务，键为任务编号
+	running bool              // 是否已启动
+	stop    chan struct{}     // 停止信号
+	wg      sync.WaitGroup    // 执行中的任务
+	mu      sync.Mutex
+}
+
+// New 创建一个定时执行器
+func New() *Scheduler {
+	s := new(Scheduler)
+	s.entries = make(map[string]*entry)
+	return s
+}
+
+// Add 添加一个任务，spec 为 cron 表达式，如 "0 * * * *"，或 "@every 30m"
+func (s *Scheduler) Add(t *task.Task, spec string) error {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+	return s.add(t, schedule, spec)
+}
+
+// AddInterval 添加一个任务，每隔 d 执行一次
+func (s *Scheduler) AddInterval(t *task.Task, d time.Duration) error {
+	return s.add(t, cron.Every(d), "@every "+d.String())
+}
+
+// add 添加一个任务，任务编号不能重复
+func (s *Scheduler) add(t *task.Task, schedule cron.Schedule, spec string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[t.ID()]; ok {
+		return fmt.Errorf("schedule: task %s already added", t.ID())
+	}
+	e := &entry{task: t, schedule: schedule}
+	e.status.ID = t.ID()
+	e.status.Name = t.Name()
+	e.status.Spec = spec
+	e.status.Next = schedule.Next(time.Now())
+	s.entries[t.ID()] = e
+	return nil
+}
+
+// Remove 移除一个任务，执行中的任务不会停止
+func (s *Scheduler) Remove(id string) {
+	s.mu.Lock()
+	delete(s.entries, id)
+	s.mu.Unlock()
+}
+
+// Start 开始定时执行，不阻塞
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	go s.loop(s.stop)
+}
+
+// Stop 停止定时执行，停止执行中的任务并保存队列，等待任务结束
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stop)
+	var running []*task.Task
+	for _, e := range s.entries {
+		if e.status.Running {
+			running = append(running, e.task)
+		}
+	}
+	s.mu.Unlock()
+	for _, t := range running {
+		go t.Stop()
+	}
+	s.wg.Wait()
+}
+
+// Trigger 立即执行一个任务，任务在执行中时返回错误
+func (s *Scheduler) Trigger(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return fmt.Errorf("schedule: task %s not found", id)
+	}
+	if e.status.Running {
+		return fmt.Errorf("schedule: task %s is running", id)
+	}
+	s.run(e)
+	return nil
+}
+
+// Status 返回一个任务的执行状态
+func (s *Scheduler) Status(id string) (Status, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return Status{}, false
+	}
+	return e.status, true
+}
+
+// Statuses 返回全部任务的执行状态，按任务编号排序
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	list := make([]Status, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, e.status)
+	}
+	s.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// loop 每秒检查一次到期的任务
+func (s *Scheduler) loop(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for _, e := range s.entries {
+				if e.status.Next.IsZero() || now.Before(e.status.Next) {
+					continue
+				}
+				e.status.Next = e.schedule.Next(now)
+				if e.status.Running {
+					e.status.Skipped++
+					log.Warnf("schedule: task %s is still running, skipped\n", e.status.ID)
+					continue
+				}
+				s.run(e)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// run 执行一次任务，调用时需持有锁
+func (s *Scheduler) run(e *entry) {
+	if e.status.Runs > 0 {
+		e.task.Reset()
+	}
+	e.status.Running = true
+	e.status.Runs++
+	e.status.LastStart = time.Now()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := e.task.Run()
+		s.mu.Lock()
+		e.status.Running = false
+		e.status.LastEnd = time.Now()
+		e.status.LastError = ""
+		if err != nil {
+			e.status.LastError = err.Error()
+		}
+		s.mu.Unlock()
+	}()
+}
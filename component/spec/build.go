@@ -0,0 +1,399 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/safeie/spider/component/dedup"
+	"github.com/safeie/spider/component/proxy"
+	"github.com/safeie/spider/component/recrawl"
+	"github.com/safeie/spider/component/task"
+	"github.com/safeie/spider/component/url"
+	"github.com/safeie/spider/component/useragent"
+)
+
+var pageTypes = map[string]int{
+	"":     url.PageTypeHTML,
+	"html": url.PageTypeHTML,
+	"json": url.PageTypeJSON,
+	"text": url.PageTypeText,
+	"xml":  url.PageTypeXML,
+	"csv":  url.PageTypeCSV,
+	"xlsx": url.PageTypeXLSX,
+	"pdf":  url.PageTypePDF,
+}
+
+var matchTypes = map[string]int{
+	"":            url.MatchTypeSelector,
+	"selector":    url.MatchTypeSelector,
+	"substring":   url.MatchTypeSubString,
+	"regexp":      url.MatchTypeRegexp,
+	"jsonpath":    url.MatchTypeJSONPath,
+	"xpath":       url.MatchTypeXPath,
+	"re2":         url.MatchTypeRE2,
+	"readability": url.MatchTypeReadability,
+	"jsdata":      url.MatchTypeJSData,
+	"feed":        url.MatchTypeFeed,
+	"table":       url.MatchTypeTable,
+}
+
+var sourceTypes = map[string]int{
+	"":           url.SourceTypeContext,
+	"context":    url.SourceTypeContext,
+	"attach":     url.SourceTypeAttach,
+	"structured": url.SourceTypeStructured,
+}
+
+var fieldTypes = map[string]int{
+	"":       url.FieldTypeAny,
+	"string": url.FieldTypeString,
+	"int":    url.FieldTypeInt,
+	"float":  url.FieldTypeFloat,
+	"bool":   url.FieldTypeBool,
+	"time":   url.FieldTypeTime,
+	"url":    url.FieldTypeURL,
+	"list":   url.FieldTypeList,
+}
+
+var errorPolicies = map[string]int{
+	"":        url.ErrorPolicyInherit,
+	"ignore":  url.ErrorPolicyIgnore,
+	"partial": url.ErrorPolicyPartial,
+	"drop":    url.ErrorPolicyDrop,
+	"retry":   url.ErrorPolicyRetry,
+	"fail":    url.ErrorPolicyFail,
+}
+
+var linkDomains = map[string]int{
+	"":          url.LinkDomainAny,
+	"any":       url.LinkDomainAny,
+	"same":      url.LinkDomainSame,
+	"subdomain": url.LinkDomainSubdomain,
+}
+
+var workflows = map[string]func(r *task.Rule){
+	"urls":     func(r *task.Rule) { r.URLs() },
+	"save":     func(r *task.Rule) { r.Save() },
+	"drop":     func(r *task.Rule) { r.Drop() },
+	"row":      nil, // 需要字段，构建时单独处理
+	"paginate": nil, // 需要分页描述，构建时单独处理
+}
+
+var filters = map[string]url.FieldFilterFunc{
+	"default":         url.FilterGroupDefault,
+	"remove_blank":    url.FilterRemoveBlank,
+	"remove_script":   url.FilterRemoveScript,
+	"remove_note":     url.FilterRemoveNote,
+	"remove_style":    url.FilterRemoveStyle,
+	"remove_image":    url.FilterRemoveImgage,
+	"remove_a":        url.FilterRemoveA,
+	"remove_cdata":    url.FilterRemoveXMLCDATA,
+	"trim_space":      func(f *url.Field) { f.TrimSpace() },
+	"html_unescape":   url.FilterHTMLUnescape,
+	"normalize_space": url.FilterNormalizeSpace,
+	"markdown":        url.FilterMarkdown,
+	"chinese_number":  url.FilterChineseNumber,
+	"currency":        url.FilterCurrency,
+}
+
+// filterFactories 带参数的过滤器，任务描述中写作 name(arg)，如 number(de), date(2006-01-02), query_param(id)，
+// 不带括号时参数为空，返回 nil 表示参数无效
+var filterFactories = map[string]func(arg string) url.FieldFilterFunc{
+	"number": url.FilterNumber,
+	"price":  url.FilterPrice,
+	"date":   url.FilterDate,
+	"query_param": func(arg string) url.FieldFilterFunc {
+		if arg == "" {
+			return nil
+		}
+		return url.FilterQueryParam(arg)
+	},
+}
+var filtersLock sync.RWMutex
+
+// MatchType 根据名称返回字段匹配类型
+func MatchType(name string) (int, bool) {
+	v, ok := matchTypes[name]
+	return v, ok
+}
+
+// RegisterFilter 注册一个命名的字段过滤器，任务描述中可以通过名称引用
+func RegisterFilter(name string, fn url.FieldFilterFunc) {
+	filtersLock.Lock()
+	filters[name] = fn
+	filtersLock.Unlock()
+}
+
+// RegisterFilterFactory 注册一个带参数的字段过滤器，任务描述中通过 name(arg) 引用
+func RegisterFilterFactory(name string, factory func(arg string) url.FieldFilterFunc) {
+	filtersLock.Lock()
+	filterFactories[name] = factory
+	filtersLock.Unlock()
+}
+
+// lookupFilter 根据名称获取字段过滤器，name(arg) 形式的名称使用带参数的过滤器
+func lookupFilter(name string) (url.FieldFilterFunc, bool) {
+	filtersLock.RLock()
+	defer filtersLock.RUnlock()
+	if fn, ok := filters[name]; ok {
+		return fn, true
+	}
+	arg := ""
+	if p := strings.Index(name, "("); p > 0 && strings.HasSuffix(name, ")") {
+		name, arg = name[:p], strings.TrimSpace(name[p+1:len(name)-1])
+	}
+	factory, ok := filterFactories[name]
+	if !ok {
+		return nil, false
+	}
+	fn := factory(arg)
+	return fn, fn != nil
+}
+
+// Build 根据任务描述构建任务，save 为空时使用任务默认的存储方法
+func (s *Spec) Build(save task.SaveFunc) (*task.Task, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	t := task.New(s.ID, s.Name, s.Domain, s.ConfigDir)
+	s.applyFetchOption(t)
+	if s.Dedup != nil {
+		store, err := s.Dedup.open()
+		if err != nil {
+			return nil, fmt.Errorf("spec: open dedup store error: %v", err)
+		}
+		t.SetDedupStore(store)
+		if s.Dedup.FetchedFile != "" {
+			fetched, err := s.Dedup.openFile(s.Dedup.FetchedFile)
+			if err != nil {
+				return nil, fmt.Errorf("spec: open dedup fetched store error: %v", err)
+			}
+			t.SetFetchedStore(fetched)
+		}
+	}
+	if s.Recrawl != nil {
+		sched, err := s.Recrawl.open()
+		if err != nil {
+			return nil, fmt.Errorf("spec: open recrawl file error: %v", err)
+		}
+		t.SetRecrawl(sched)
+	}
+	if s.Interval > 0 {
+		t.SetInterval(s.Interval)
+	}
+	t.SetRoutineNum(s.RoutineNum)
+	t.SetErrorContinue(s.ErrorContinue)
+	t.SetAutoSession(s.AutoSession)
+	if len(s.InitURLs) > 0 {
+		initURLs := s.InitURLs
+		t.SetURLinitFunc(func() []string {
+			return initURLs
+		})
+	}
+
+	for _, rs := range s.Rules {
+		r := t.Rule(rs.Rule)
+		r.SetName(rs.Name).
+			SetPageType(pageTypes[rs.PageType]).
+			SetExpand(rs.Expand).
+			ForceUpdate(rs.ForceUpdate).
+			SetRecrawl(!rs.NoRecrawl).
+			SetErrorPolicy(errorPolicies[rs.OnError]).
+			PK(rs.PK)
+		for _, name := range rs.Filters {
+			fn, _ := lookupFilter(name)
+			r.SetFieldFilterFunc(fn)
+		}
+		if d := rs.NearDuplicate; d != nil {
+			distance := d.Distance
+			if distance == 0 {
+				distance = 3
+			}
+			r.SkipNearDuplicate(distance, d.Fields...)
+		}
+		for _, w := range rs.Workflow {
+			if w == "row" {
+				fs := make([]*url.Field, 0, len(rs.Fields))
+				for _, f := range rs.Fields {
+					fs = append(fs, f.build(t))
+				}
+				r.Row(fs...)
+				continue
+			}
+			if w == "urls" && rs.Links != nil {
+				r.URLs(rs.Links.build())
+				continue
+			}
+			if w == "paginate" {
+				r.Paginate(rs.Pagination.build())
+				continue
+			}
+			workflows[w](r)
+		}
+		if save != nil {
+			r.SetSaveFunc(save, nil, nil)
+		}
+	}
+
+	return t, nil
+}
+
+// build 构建链接范围
+func (l *LinkSpec) build() *url.LinkScope {
+	return url.NewLinkScope().
+		SetSelector(l.Selectors...).
+		SetInclude(l.Include...).
+		SetExclude(l.Exclude...).
+		SetDomain(linkDomains[l.Domain]).
+		SetNofollow(l.Nofollow)
+}
+
+// build 构建分页
+func (p *PageSpec) build() *task.Pagination {
+	pg := task.NewPagination().
+		SetPageURL(p.PageURL).
+		SetPageParam(p.PageParam).
+		SetMaxPages(p.MaxPages)
+	if p.Next != "" {
+		pg.SetNext(matchTypes[p.MatchType], p.Next)
+	}
+	if p.Cursor != "" {
+		pg.SetCursor(matchTypes[p.MatchType], p.Cursor)
+	}
+	if p.Start != 0 || p.Step != 0 {
+		pg.SetPageStart(p.Start, p.Step)
+	}
+	if p.StopOnEmpty != nil {
+		pg.StopOnEmpty(*p.StopOnEmpty)
+	}
+	if p.StopOnRepeat != nil {
+		pg.StopOnRepeat(*p.StopOnRepeat)
+	}
+	return pg
+}
+
+// open 打开判断重复的存储
+func (d *DedupSpec) open() (dedup.Store, error) {
+	return d.openFile(d.File)
+}
+
+// openFile 按存储类型打开指定文件的存储
+func (d *DedupSpec) openFile(file string) (dedup.Store, error) {
+	switch d.Type {
+	case "bloom":
+		if file == "" {
+			return dedup.NewBloom(d.Capacity, d.FPRate), nil
+		}
+		return dedup.OpenBloom(file, d.Capacity, d.FPRate)
+	case "disk":
+		return dedup.OpenDisk(file, d.Capacity)
+	}
+	return dedup.NewMap(), nil
+}
+
+// open 打开重新抓取调度器
+func (c *RecrawlSpec) open() (*recrawl.Scheduler, error) {
+	sched := recrawl.NewScheduler()
+	if c.File != "" {
+		var err error
+		if sched, err = recrawl.OpenScheduler(c.File); err != nil {
+			return nil, err
+		}
+	}
+	return sched.SetInterval(time.Duration(c.Interval)*time.Second).
+		SetIntervalRange(time.Duration(c.MinInterval)*time.Second, time.Duration(c.MaxInterval)*time.Second).
+		SetHistorySize(c.History), nil
+}
+
+// applyFetchOption 设置任务的抓取参数
+func (s *Spec) applyFetchOption(t *task.Task) {
+	if s.EnableJS {
+		t.EnableJS(true)
+	}
+	if s.Method != "" {
+		t.SetMethod(strings.ToUpper(s.Method))
+	}
+	if s.Charset != "" {
+		t.SetCharset(strings.ToUpper(s.Charset))
+	}
+	if s.Cookie != "" {
+		t.SetCookie(s.Cookie)
+	}
+	if s.UserAgent != "" {
+		t.SetUserAgent(useragent.Custom, s.UserAgent)
+	}
+	if s.Proxy != "" {
+		t.SetProxy(proxy.TypeCustom, s.Proxy)
+	}
+	if s.Timeout > 0 {
+		t.SetTimeout(s.Timeout)
+	}
+	if s.RenderDelay > 0 {
+		t.SetRenderDelay(s.RenderDelay)
+	}
+	for k, v := range s.Headers {
+		t.SetHeader(k, v)
+	}
+	for k, v := range s.Params {
+		t.SetParam(k, v)
+	}
+	if c := s.Canonical; c != nil {
+		if c.Disable {
+			t.SetCanonicalizer(nil)
+			return
+		}
+		canon := url.NewCanonicalizer().
+			SetRemoveFragment(!c.KeepFragment).
+			SetTrailingSlash(c.TrailingSlash)
+		if c.StripParams != nil {
+			canon.SetStripParams(c.StripParams...)
+		}
+		t.SetCanonicalizer(canon)
+	}
+}
+
+// build 根据字段描述构建字段，含子字段
+func (f *FieldSpec) build(t *task.Task) *url.Field {
+	field := t.NewField(f.Name, f.Alias).
+		SetSourceType(sourceTypes[f.Source]).
+		SetMatchRule(matchTypes[f.MatchType], f.MatchRule).
+		SetRepeat(f.Repeat).
+		SetExpand(f.Expand).
+		SetFixURL(f.FixURL).
+		SetType(fieldTypes[f.Type], f.Layout).
+		SetRequired(f.Required).
+		SetDefault(f.Default).
+		SetErrorPolicy(errorPolicies[f.OnError])
+	for _, name := range f.Filters {
+		fn, _ := lookupFilter(name)
+		field.SetFilterFunc(fn)
+	}
+	if f.Remote != nil {
+		field.SetRemote(f.Remote.build(t))
+	}
+	for _, c := range f.Children {
+		field.SetChildren(c.build(t))
+	}
+	return field
+}
+
+// build 根据远程字段描述构建远程获取
+func (r *RemoteSpec) build(t *task.Task) *url.Remote {
+	remote := url.NewRemote(t, pageTypes[r.PageType], r.URL)
+	if r.EnableJS {
+		remote.EnableJS(true)
+	}
+	if r.Method != "" {
+		remote.SetMethod(strings.ToUpper(r.Method))
+	}
+	for k, v := range r.Headers {
+		remote.SetHeader(k, v)
+	}
+	for k, v := range r.Params {
+		remote.SetParam(k, v)
+	}
+	return remote
+}
@@ -0,0 +1,349 @@
+// Package spec 提供声明式的任务描述，用JSON文件描述一个任务，然后构建成 task.Task 执行
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/safeie/spider/common/cron"
+	"github.com/safeie/spider/common/util"
+	"github.com/safeie/spider/component/parser"
+)
+
+// Spec 任务描述
+type Spec struct {
+	ID            string            `json:"id"`             // 任务编号
+	Name          string            `json:"name"`           // 任务名称
+	Domain        string            `json:"domain"`         // 任务域名
+	ConfigDir     string            `json:"config_dir"`     // 配置目录，为空使用默认目录
+	InitURLs      []string          `json:"init_urls"`      // 入口URL
+	Schedule      string            `json:"schedule"`       // 定时执行的 cron 表达式或 @every 间隔，spider daemon 使用
+	Interval      int               `json:"interval"`       // 采集间隔，单位 毫秒
+	RoutineNum    int               `json:"routine_num"`    // 协程数量
+	ErrorContinue bool              `json:"error_continue"` // 出错后是否继续
+	AutoSession   bool              `json:"auto_session"`   // 是否自动记录会话
+	EnableJS      bool              `json:"enable_js"`      // 是否启用JS渲染
+	Method        string            `json:"method"`         // HTTP请求方法
+	Charset       string            `json:"charset"`        // 页面编码
+	Cookie        string            `json:"cookie"`         // Cookie
+	UserAgent     string            `json:"user_agent"`     // 自定义UserAgent
+	Proxy         string            `json:"proxy"`          // 自定义代理地址
+	Timeout       int               `json:"timeout"`        // 抓取超时，单位 秒
+	RenderDelay   int               `json:"render_delay"`   // JS渲染等待时间
+	Headers       map[string]string `json:"headers"`        // HTTP请求头
+	Params        map[string]string `json:"params"`         // HTTP请求参数
+	Canonical     *CanonicalSpec    `json:"canonical"`      // URL规范化，用于判断重复，默认 url.NewCanonicalizer()
+	Dedup         *DedupSpec        `json:"dedup"`          // 判断重复的存储，默认内存存储
+	Recrawl       *RecrawlSpec      `json:"recrawl"`        // 重新抓取，设置后任务一直运行，按内容变化的频率重新抓取页面
+	Rules         []*RuleSpec       `json:"rules"`          // 采集规则
+}
+
+// DedupSpec 判断重复的存储描述
+type DedupSpec struct {
+	Type     string  `json:"type"`     // 存储类型 map, bloom, disk，默认 map
+	File     string  `json:"file"`     // 保存的文件，disk 必须设置，bloom 可选
+	Capacity int     `json:"capacity"` // 预计的URL数量
+	FPRate   float64 `json:"fp_rate"`  // bloom 的误判率，默认 0.001
+	// 抓取记录(URL和内容摘要)保存的文件，类型同 type，为空时保存在内存中，type 为 map 时不能设置
+	FetchedFile string `json:"fetched_file"`
+}
+
+// RecrawlSpec 重新抓取描述，间隔的单位为 秒，0 使用默认值
+type RecrawlSpec struct {
+	File        string `json:"file"`         // 保存抓取记录的文件，为空不保存
+	Interval    int    `json:"interval"`     // 第一次抓取后的间隔，默认 1小时
+	MinInterval int    `json:"min_interval"` // 最短间隔，默认 5分钟
+	MaxInterval int    `json:"max_interval"` // 最长间隔，默认 7天
+	History     int    `json:"history"`      // 每个URL保留的变化记录数量，默认 10
+}
+
+// CanonicalSpec URL规范化描述，未设置的项使用默认值
+type CanonicalSpec struct {
+	Disable       bool     `json:"disable"`        // 不规范化，使用原始的URL判断重复
+	StripParams   []string `json:"strip_params"`   // 去掉的查询参数，支持 * 号通配，设置后替换默认的跟踪参数
+	KeepFragment  bool     `json:"keep_fragment"`  // 保留锚点
+	TrailingSlash bool     `json:"trailing_slash"` // 去掉路径末尾的斜杠
+}
+
+// RuleSpec 规则描述
+type RuleSpec struct {
+	Rule          string             `json:"rule"`           // 规则的正则
+	Name          string             `json:"name"`           // 规则名称
+	PageType      string             `json:"page_type"`      // 页面类型 html, json, text, xml, csv, xlsx, pdf
+	Workflow      []string           `json:"workflow"`       // 工作流 urls, row, save, paginate, drop
+	PK            string             `json:"pk"`             // 主键字段
+	Expand        bool               `json:"expand"`         // 展开单个复数字段
+	ForceUpdate   bool               `json:"force_update"`   // 是否强制更新
+	NoRecrawl     bool               `json:"no_recrawl"`     // 不重新抓取符合规则的页面
+	Filters       []string           `json:"filters"`        // 规则级别的字段过滤器
+	OnError       string             `json:"on_error"`       // 字段出错时的策略 ignore, partial, drop, retry, fail，默认 ignore
+	Fields        []*FieldSpec       `json:"fields"`         // 数据字段
+	Pagination    *PageSpec          `json:"pagination"`     // 分页，工作流 paginate 使用
+	Links         *LinkSpec          `json:"links"`          // 链接范围，工作流 urls 使用，默认全部链接
+	NearDuplicate *NearDuplicateSpec `json:"near_duplicate"` // 跳过内容近似重复的数据
+}
+
+// NearDuplicateSpec 近似重复描述
+type NearDuplicateSpec struct {
+	Fields   []string `json:"fields"`   // 计算指纹的字段，默认全部字段
+	Distance int      `json:"distance"` // 认为近似的最大海明距离 0-63，默认 3
+}
+
+// LinkSpec 链接范围描述
+type LinkSpec struct {
+	Selectors []string `json:"selectors"` // 链接所在的区域，CSS选择器
+	Include   []string `json:"include"`   // 链接需要符合其中一个正则
+	Exclude   []string `json:"exclude"`   // 符合其中一个正则的链接被丢弃
+	Domain    string   `json:"domain"`    // 域名限制 any, same, subdomain，默认 any
+	Nofollow  bool     `json:"nofollow"`  // 是否跳过 nofollow 的链接
+}
+
+// PageSpec 分页描述，next, cursor, page_url/page_param 三选一
+type PageSpec struct {
+	MatchType    string `json:"match_type"`     // next 和 cursor 的匹配类型
+	Next         string `json:"next"`           // 下一页链接的匹配规则
+	Cursor       string `json:"cursor"`         // 游标的匹配规则，游标替换 page_url 的占位符或作为 page_param 的值
+	PageURL      string `json:"page_url"`       // 页码的URL模板，{{.}} 为页码占位符
+	PageParam    string `json:"page_param"`     // 页码的请求参数名
+	Start        int    `json:"start"`          // 起始页码，默认 1
+	Step         int    `json:"step"`           // 页码步长，默认 1
+	MaxPages     int    `json:"max_pages"`      // 最大页数，0 不限制
+	StopOnEmpty  *bool  `json:"stop_on_empty"`  // 没有数据时停止，默认 true
+	StopOnRepeat *bool  `json:"stop_on_repeat"` // 内容与上一页相同时停止，默认 true
+}
+
+// FieldSpec 字段描述
+type FieldSpec struct {
+	Name      string       `json:"name"`       // 字段名
+	Alias     string       `json:"alias"`      // 别名
+	Source    string       `json:"source"`     // 来源 context, attach, structured
+	MatchType string       `json:"match_type"` // 匹配类型 selector, substring, regexp, jsonpath, xpath, re2, readability, jsdata, feed, table
+	MatchRule string       `json:"match_rule"` // 匹配规则
+	Repeat    bool         `json:"repeat"`     // 是否重复项
+	Expand    bool         `json:"expand"`     // 展开单个复数字段
+	FixURL    bool         `json:"fix_url"`    // 是否修复URL
+	Filters   []string     `json:"filters"`    // 字段过滤器
+	Type      string       `json:"type"`       // 值的类型 string, int, float, bool, time, url, list，默认不转换
+	Layout    string       `json:"layout"`     // 时间格式，Go 的时间格式，如 2006-01-02 15:04，默认 RFC3339
+	Required  bool         `json:"required"`   // 是否必填
+	Default   interface{}  `json:"default"`    // 默认值，值为空或类型转换失败时使用
+	OnError   string       `json:"on_error"`   // 出错时的策略 ignore, partial, drop, retry, fail，默认使用规则的策略
+	Remote    *RemoteSpec  `json:"remote"`     // 远程字段
+	Children  []*FieldSpec `json:"children"`   // 子字段
+}
+
+// RemoteSpec 远程字段描述
+type RemoteSpec struct {
+	URL      string            `json:"url"`       // 远程URL，{{.}} 为字段值占位符
+	PageType string            `json:"page_type"` // 页面类型
+	Method   string            `json:"method"`    // HTTP请求方法
+	EnableJS bool              `json:"enable_js"` // 是否启用JS渲染
+	Headers  map[string]string `json:"headers"`   // HTTP请求头
+	Params   map[string]string `json:"params"`    // HTTP请求参数
+}
+
+// Load 从文件加载任务描述
+func Load(file string) (*Spec, error) {
+	body, err := util.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("spec.Load read file error: %v", err)
+	}
+	return Parse(body)
+}
+
+// Parse 解析任务描述
+func Parse(body []byte) (*Spec, error) {
+	s := new(Spec)
+	if err := json.Unmarshal(body, s); err != nil {
+		return nil, fmt.Errorf("spec.Parse json error: %v", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Marshal 编码任务描述为JSON
+func (s *Spec) Marshal() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// Validate 检查任务描述是否完整
+func (s *Spec) Validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("spec: id is empty")
+	}
+	if len(s.Rules) == 0 {
+		return fmt.Errorf("spec: rules is empty")
+	}
+	if s.Schedule != "" {
+		if _, err := cron.Parse(s.Schedule); err != nil {
+			return fmt.Errorf("spec: schedule %v", err)
+		}
+	}
+	if d := s.Dedup; d != nil {
+		switch d.Type {
+		case "", "map", "bloom":
+		case "disk":
+			if d.File == "" {
+				return fmt.Errorf("spec: dedup disk needs file")
+			}
+		default:
+			return fmt.Errorf("spec: unknown dedup type %q", d.Type)
+		}
+		if d.FPRate < 0 || d.FPRate >= 1 {
+			return fmt.Errorf("spec: dedup fp_rate should be in [0, 1)")
+		}
+		if d.FetchedFile != "" && (d.Type == "" || d.Type == "map") {
+			return fmt.Errorf("spec: dedup fetched_file needs type bloom or disk")
+		}
+		if d.FetchedFile != "" && d.FetchedFile == d.File {
+			return fmt.Errorf("spec: dedup fetched_file should not be the same as file")
+		}
+	}
+	if c := s.Recrawl; c != nil {
+		if c.Interval < 0 || c.MinInterval < 0 || c.MaxInterval < 0 || c.History < 0 {
+			return fmt.Errorf("spec: recrawl intervals and history should not be negative")
+		}
+		if c.MinInterval > 0 && c.MaxInterval > 0 && c.MinInterval > c.MaxInterval {
+			return fmt.Errorf("spec: recrawl min_interval is greater than max_interval")
+		}
+	}
+	for i, r := range s.Rules {
+		if r.Rule == "" {
+			return fmt.Errorf("spec: rules[%d] rule is empty", i)
+		}
+		if _, err := regexp.Compile(r.Rule); err != nil {
+			return fmt.Errorf("spec: rules[%d] rule %v", i, err)
+		}
+		if _, ok := pageTypes[r.PageType]; !ok {
+			return fmt.Errorf("spec: rules[%d] unknown page_type %q", i, r.PageType)
+		}
+		for _, w := range r.Workflow {
+			if _, ok := workflows[w]; !ok {
+				return fmt.Errorf("spec: rules[%d] unknown workflow %q", i, w)
+			}
+		}
+		for _, w := range r.Workflow {
+			if w == "paginate" && r.Pagination == nil {
+				return fmt.Errorf("spec: rules[%d] workflow paginate needs pagination", i)
+			}
+		}
+		if r.Links != nil {
+			if err := r.Links.validate(); err != nil {
+				return fmt.Errorf("spec: rules[%d] %v", i, err)
+			}
+		}
+		if r.Pagination != nil {
+			if err := r.Pagination.validate(); err != nil {
+				return fmt.Errorf("spec: rules[%d] %v", i, err)
+			}
+		}
+		for _, name := range r.Filters {
+			if _, ok := lookupFilter(name); !ok {
+				return fmt.Errorf("spec: rules[%d] unknown filter %q", i, name)
+			}
+		}
+		if _, ok := errorPolicies[r.OnError]; !ok {
+			return fmt.Errorf("spec: rules[%d] unknown on_error %q", i, r.OnError)
+		}
+		if d := r.NearDuplicate; d != nil && (d.Distance < 0 || d.Distance > 63) {
+			return fmt.Errorf("spec: rules[%d] near_duplicate distance %d out of range 0-63", i, d.Distance)
+		}
+		for _, f := range r.Fields {
+			if err := f.validate(); err != nil {
+				return fmt.Errorf("spec: rules[%d] %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate 检查字段描述，含子字段
+func (f *FieldSpec) validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("field name is empty")
+	}
+	if _, ok := sourceTypes[f.Source]; !ok {
+		return fmt.Errorf("field[%s] unknown source %q", f.Name, f.Source)
+	}
+	if _, ok := matchTypes[f.MatchType]; !ok {
+		return fmt.Errorf("field[%s] unknown match_type %q", f.Name, f.MatchType)
+	}
+	switch f.MatchType {
+	case "re2":
+		if _, err := regexp.Compile(f.MatchRule); err != nil {
+			return fmt.Errorf("field[%s] %v", f.Name, err)
+		}
+	case "regexp":
+		if _, err := parser.RegexpRule(f.MatchRule); err != nil {
+			return fmt.Errorf("field[%s] %v", f.Name, err)
+		}
+	case "xpath":
+		if err := parser.CompileXPath(f.MatchRule); f.MatchRule != "" && err != nil {
+			return fmt.Errorf("field[%s] xpath %v", f.Name, err)
+		}
+	}
+	for _, name := range f.Filters {
+		if _, ok := lookupFilter(name); !ok {
+			return fmt.Errorf("field[%s] unknown filter %q", f.Name, name)
+		}
+	}
+	if _, ok := fieldTypes[f.Type]; !ok {
+		return fmt.Errorf("field[%s] unknown type %q", f.Name, f.Type)
+	}
+	if f.Layout != "" && f.Type != "time" {
+		return fmt.Errorf("field[%s] layout is only for type time", f.Name)
+	}
+	if _, ok := errorPolicies[f.OnError]; !ok {
+		return fmt.Errorf("field[%s] unknown on_error %q", f.Name, f.OnError)
+	}
+	if f.Remote != nil {
+		if f.Remote.URL == "" {
+			return fmt.Errorf("field[%s] remote url is empty", f.Name)
+		}
+		if _, ok := pageTypes[f.Remote.PageType]; !ok {
+			return fmt.Errorf("field[%s] remote unknown page_type %q", f.Name, f.Remote.PageType)
+		}
+	}
+	for _, c := range f.Children {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate 检查分页描述
+func (p *PageSpec) validate() error {
+	if _, ok := matchTypes[p.MatchType]; !ok {
+		return fmt.Errorf("pagination unknown match_type %q", p.MatchType)
+	}
+	if p.Next == "" && p.Cursor == "" && p.PageURL == "" && p.PageParam == "" {
+		return fmt.Errorf("pagination needs next, cursor, page_url or page_param")
+	}
+	if p.Next != "" && p.Cursor != "" {
+		return fmt.Errorf("pagination next and cursor are exclusive")
+	}
+	if p.Cursor != "" && p.PageURL == "" && p.PageParam == "" {
+		return fmt.Errorf("pagination cursor needs page_url or page_param")
+	}
+	if p.MaxPages < 0 {
+		return fmt.Errorf("pagination max_pages is negative")
+	}
+	return nil
+}
+
+// validate 检查链接范围描述
+func (l *LinkSpec) validate() error {
+	if _, ok := linkDomains[l.Domain]; !ok {
+		return fmt.Errorf("links unknown domain %q", l.Domain)
+	}
+	for _, p := range append(append([]string{}, l.Include...), l.Exclude...) {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("links %v", err)
+		}
+	}
+	return nil
+}
@@ -30,6 +30,12 @@ type AntiSpiderFunc func(uri *url.URI) bool
 // ErrFetchDuplicated 抓取重复
 var ErrFetchDuplicated = errors.New("fetch duplicated")
 
+// ErrFetchUnchanged 重新抓取的页面内容没有变化，跳过后续的处理
+var ErrFetchUnchanged = errors.New("fetch unchanged")
+
+// ErrFetchDeferred 触发了反采集策略，URL重新加入队列稍后抓取，跳过后续的处理
+var ErrFetchDeferred = errors.New("fetch deferred")
+
 // FetcherPool 抓取器池
 type FetcherPool struct {
 	kit   int
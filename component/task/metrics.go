@@ -0,0 +1,114 @@
+package task
+
+import (
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"github.com/safeie/spider/common/metrics"
+	"github.com/safeie/spider/component/fetcher"
+)
+
+// 数据行的处理结果
+const (
+	rowSaved     = "saved"     // 保存成功
+	rowDropped   = "dropped"   // 有字段错误或数据为空，丢弃
+	rowDuplicate = "duplicate" // 内容近似重复，跳过
+	rowError     = "error"     // 保存失败
+)
+
+// taskMetrics 任务的指标，多个任务注册到同一个 Registry 时共用指标，按 task 标签区分
+type taskMetrics struct {
+	fetches    *metrics.Counter   // 抓取次数：task source host status engine
+	fetchTime  *metrics.Histogram // 抓取耗时：task source host engine
+	fetchBytes *metrics.Counter   // 抓取的字节数：task source host
+	retries    *metrics.Counter   // 抓取重试次数：task host
+	antiSpider *metrics.Counter   // 触发反采集策略的次数：task
+	ruleRuns   *metrics.Counter   // 规则处理的URL数量：task rule result
+	ruleTime   *metrics.Histogram // 规则处理耗时：task rule
+	rows       *metrics.Counter   // 数据行：task rule result
+	queue      *metrics.Gauge     // 队列中的URL数量：task
+	active     *metrics.Gauge     // 执行中的抓取协程数量：task
+}
+
+// SetMetrics 设置指标注册表，记录抓取、规则处理、数据行和队列的指标，多个任务可以共用一个注册表
+//
+// 指标名称以 spider_ 开头，Registry 实现了 http.Handler，可以挂载为 Prometheus 的采集地址
+func (t *Task) SetMetrics(reg *metrics.Registry) *Task {
+	if reg == nil {
+		t.metrics = nil
+		return t
+	}
+	m := new(taskMetrics)
+	m.fetches = reg.Counter("spider_fetches_total", "Fetches by host, status and engine, source is page or remote.", "task", "source", "host", "status", "engine")
+	m.fetchTime = reg.Histogram("spider_fetch_duration_seconds", "Fetch latency in seconds, including retries.", nil, "task", "source", "host", "engine")
+	m.fetchBytes = reg.Counter("spider_fetch_bytes_total", "Bytes of fetched bodies.", "task", "source", "host")
+	m.retries = reg.Counter("spider_fetch_retries_total", "Fetch retries after a failed attempt.", "task", "host")
+	m.antiSpider = reg.Counter("spider_anti_spider_total", "Fetches rejected by the anti-spider check.", "task")
+	m.ruleRuns = reg.Counter("spider_rule_runs_total", "URLs processed by rule, result is ok or error.", "task", "rule", "result")
+	m.ruleTime = reg.Histogram("spider_rule_duration_seconds", "Time spent processing an URL by rule.", nil, "task", "rule")
+	m.rows = reg.Counter("spider_rows_total", "Rows by rule, result is saved, dropped, duplicate or error.", "task", "rule", "result")
+	m.queue = reg.Gauge("spider_queue_depth", "URLs waiting in the queue.", "task")
+	m.active = reg.Gauge("spider_active_routines", "Fetch routines in progress.", "task")
+	m.queue.SetFunc(func() float64 { return float64(t.url.Len()) }, t.id)
+	m.active.SetFunc(func() float64 { return float64(t.Active()) }, t.id)
+	t.metrics = m
+	return t
+}
+
+// ObserveFetch 记录一次抓取的指标，实现 url.FetchObserver，远程字段的抓取也会记录
+func (t *Task) ObserveFetch(source, rawurl string, engine int, res *fetcher.Response, retries int, d time.Duration, err error) {
+	m := t.metrics
+	if m == nil {
+		return
+	}
+	host := ""
+	if v, e := neturl.Parse(rawurl); e == nil {
+		host = v.Host
+	}
+	kit := "gokit"
+	if engine == fetcher.EngineWebKit {
+		kit = "webkit"
+	}
+	status := "error"
+	if res != nil && res.Code > 0 {
+		status = strconv.Itoa(res.Code)
+	}
+	m.fetches.Inc(t.id, source, host, status, kit)
+	m.fetchTime.Observe(d.Seconds(), t.id, source, host, kit)
+	if res != nil {
+		m.fetchBytes.Add(float64(len(res.Body)), t.id, source, host)
+	}
+	if retries > 0 {
+		m.retries.Add(float64(retries), t.id, host)
+	}
+}
+
+// observeAntiSpider 记录一次触发反采集策略
+func (m *taskMetrics) observeAntiSpider(task string) {
+	if m == nil {
+		return
+	}
+	m.antiSpider.Inc(task)
+}
+
+// observeRule 记录规则处理一个URL
+func (m *taskMetrics) observeRule(task, rule string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.ruleRuns.Inc(task, rule, result)
+	m.ruleTime.Observe(d.Seconds(), task, rule)
+}
+
+// observeRow 记录一条数据行的处理结果
+func (m *taskMetrics) observeRow(task, rule, result string) {
+	if m == nil {
+		return
+	}
+	m.rows.Inc(task, rule, result)
+}
@@ -0,0 +1,189 @@
+package task
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/safeie/spider/component/url"
+)
+
+const (
+	// PageKey 附加数据中分页的当前页数，第一页为 1
+	PageKey = "_page"
+	// PageHashKey 附加数据中上一页内容的摘要，用于判断重复页面
+	PageHashKey = "_page_hash"
+)
+
+// Pagination 分页，按顺序选择一种方式获取下一页：
+// 下一页链接(SetNext)，游标(SetCursor)，页码(SetPageURL 或 SetPageParam)
+// 下一页复制当前页面的附加数据和请求参数，页数和上一页的摘要只在下一页中设置，加入任务的URL列表，按规则处理
+type Pagination struct {
+	next       *url.Field // 下一页链接
+	cursor     *url.Field // 游标，如 JSON 接口返回的 next_cursor
+	pageURL    string     // 页码或游标的URL模板，使用 {{.}} 做占位符
+	pageParam  string     // 页码或游标的请求参数名
+	start      int        // 起始页码，默认 1
+	step       int        // 页码步长，默认 1，按偏移量分页时为每页条数
+	maxPages   int        // 最大页数，0 不限制
+	stopEmpty  bool       // 没有数据时停止
+	stopRepeat bool       // 内容与上一页相同时停止
+}
+
+// NewPagination 创建一个分页，默认从第 1 页开始，没有数据或内容重复时停止
+func NewPagination() *Pagination {
+	p := new(Pagination)
+	p.start = 1
+	p.step = 1
+	p.stopEmpty = true
+	p.stopRepeat = true
+	return p
+}
+
+// SetNext 设置下一页链接的匹配规则，如 a.next::attr(href)，没有匹配到时停止
+func (p *Pagination) SetNext(matchType int, matchRule string) *Pagination {
+	p.next = url.NewField("_next", "下一页").SetMatchRule(matchType, matchRule)
+	return p
+}
+
+// SetCursor 设置游标的匹配规则，如 $.paging.next_cursor，游标替换 SetPageURL 的占位符或作为 SetPageParam 的参数值
+// 没有匹配到游标时停止
+func (p *Pagination) SetCursor(matchType int, matchRule string) *Pagination {
+	p.cursor = url.NewField("_cursor", "游标").SetMatchRule(matchType, matchRule)
+	return p
+}
+
+// SetPageURL 设置页码的URL模板，如 https://example.com/list?page={{.}}
+func (p *Pagination) SetPageURL(v string) *Pagination {
+	p.pageURL = v
+	return p
+}
+
+// SetPageParam 设置页码的请求参数名，URL不变，页码作为请求参数，如 POST 接口的 pager
+func (p *Pagination) SetPageParam(v string) *Pagination {
+	p.pageParam = v
+	return p
+}
+
+// SetPageStart 设置起始页码和步长，默认都是 1，按偏移量分页时如 0, 20
+func (p *Pagination) SetPageStart(start, step int) *Pagination {
+	p.start = start
+	if step != 0 {
+		p.step = step
+	}
+	return p
+}
+
+// SetMaxPages 设置最大页数，包括第一页，0 不限制
+func (p *Pagination) SetMaxPages(v int) *Pagination {
+	p.maxPages = v
+	return p
+}
+
+// StopOnEmpty 设置没有数据时是否停止，规则有字段时全部字段为空，没有字段时没有获取到URL
+func (p *Pagination) StopOnEmpty(v bool) *Pagination {
+	p.stopEmpty = v
+	return p
+}
+
+// StopOnRepeat 设置内容与上一页相同时是否停止，用于超出页码后返回最后一页的网站
+func (p *Pagination) StopOnRepeat(v bool) *Pagination {
+	p.stopRepeat = v
+	return p
+}
+
+// Next 根据当前页面返回下一页，没有下一页时返回 nil，empty 为当前页面是否没有数据
+func (p *Pagination) Next(u *url.URI, empty bool) *url.URI {
+	page := 1
+	if v, ok := u.Get(PageKey).(int); ok {
+		page = v
+	}
+	if p.maxPages > 0 && page >= p.maxPages {
+		return nil
+	}
+	if p.stopEmpty && (empty || len(bytes.TrimSpace(u.Body)) == 0) {
+		return nil
+	}
+	sum := md5.Sum(u.Body)
+	hash := hex.EncodeToString(sum[:])
+	if p.stopRepeat && u.Get(PageHashKey) == hash {
+		return nil
+	}
+
+	var n *url.URI
+	switch {
+	case p.next != nil:
+		href := p.fetch(p.next, u)
+		if href = u.FixURL(href); href == "" || href == u.URL {
+			return nil
+		}
+		n = u.Follow(href)
+	case p.cursor != nil:
+		cursor := p.fetch(p.cursor, u)
+		if cursor == "" || cursor == u.Req.Params[p.pageParam] {
+			return nil
+		}
+		n = p.follow(u, cursor)
+	case p.pageURL != "" || p.pageParam != "":
+		n = p.follow(u, strconv.Itoa(p.start+page*p.step))
+	default:
+		return nil
+	}
+	if n == nil {
+		return nil
+	}
+	// 下一页使用相同的请求参数
+	for k, v := range u.Req.Params {
+		if _, ok := n.Req.Params[k]; !ok {
+			n.SetParam(k, v)
+		}
+	}
+	for k, v := range u.Req.Header {
+		if _, ok := n.Req.Header[k]; !ok {
+			n.SetHeader(k, v)
+		}
+	}
+	n.Set(PageKey, page+1)
+	n.Set(PageHashKey, hash)
+	return n
+}
+
+// follow 使用页码或游标创建下一页
+func (p *Pagination) follow(u *url.URI, v string) *url.URI {
+	href := u.URL
+	if p.pageURL != "" {
+		href = u.FixURL(strings.Replace(p.pageURL, "{{.}}", neturl.QueryEscape(v), -1))
+		if href == "" {
+			return nil
+		}
+	}
+	n := u.Follow(href)
+	if p.pageParam != "" {
+		n.SetParam(p.pageParam, v)
+	}
+	return n
+}
+
+// fetch 获取当前页面中分页字段的值，JSON 中的数字游标转为字符串
+func (p *Pagination) fetch(f *url.Field, u *url.URI) string {
+	f = f.Copy()
+	if err := f.Fetch(u); err != nil {
+		return ""
+	}
+	switch v := f.Value().(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		if len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+	}
+	return ""
+}
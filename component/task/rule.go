@@ -1,8 +1,14 @@
 package task
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/safeie/spider/common/log"
 	"github.com/safeie/spider/common/util"
@@ -14,26 +20,38 @@ const (
 	workFlowFetchURLs        // 获取URL
 	workFlowFetchRow         // 获取数据字段
 	workFlowSave             // 保存
+	workFlowPaginate         // 分页
 )
 
+// ErrRowFailed 字段出错且错误策略为 url.ErrorPolicyFail，任务将终止
+var ErrRowFailed = errors.New("row failed by field error policy")
+
 // Rule 任务的一个规则，不同的规则对应不同的处理流程
 type Rule struct {
-	task             *Task                 // 规则对应的任务
-	name             string                // 规则，名称，用于区分存储的数据表
-	rule             string                // 规则的字面
-	re               *regexp.Regexp        // 规则的正则
-	workflow         []int                 // 工作流，每一个数字，代表着一个执行方法
-	pageType         int                   // 页面类型，默认 HTML网页
-	forceUpdate      bool                  // 遇到采集过的页面，是否强制更新
-	row              []*url.Field          // 一条数据，由多个字段组成
-	pk               string                // 一条数据的主键，用于重复判断，默认为URL
-	expand           bool                  // 展开单个复数字段，即：一个Row只有一个字段且该字段为数组时，展开该字段为多条数据
-	fieldFilterFuncs []url.FieldFilterFunc // 字段，过滤函数，全局过滤器在字段本身过滤器之后执行
-	beforeRuleFunc   BeforeRuleFunc        // 规则，前置钩子函数，在匹配到URL后，处理前
-	afterRuleRunc    AfterRuleFunc         // 规则，后置钩子函数，在处理完绑定的方法后
-	saveFunc         SaveFunc              // 存储，存储函数
-	beforeSaveFunc   BeforeSaveFunc        // 存储，前置钩子函数
-	afterSaveFunc    AfterSaveFunc         // 存储，后置钩子函数
+	task             *Task                  // 规则对应的任务
+	name             string                 // 规则，名称，用于区分存储的数据表
+	rule             string                 // 规则的字面
+	re               *regexp.Regexp         // 规则的正则
+	workflow         []int                  // 工作流，每一个数字，代表着一个执行方法
+	pageType         int                    // 页面类型，默认 HTML网页
+	forceUpdate      bool                   // 遇到采集过的页面，是否强制更新
+	noRecrawl        bool                   // 不记录重新抓取
+	stats            *ruleStats             // 统计
+	row              []*url.Field           // 一条数据，由多个字段组成
+	pk               string                 // 一条数据的主键，用于重复判断，默认为URL
+	expand           bool                   // 展开单个复数字段，即：一个Row只有一个字段且该字段为数组时，展开该字段为多条数据
+	fieldFilterFuncs []url.FieldFilterFunc  // 字段，过滤函数，全局过滤器在字段本身过滤器之后执行
+	errorPolicy      int                    // 字段，出错时的策略，字段未设置策略时使用
+	pagination       *Pagination            // 分页，获取下一页
+	linkScopes       map[int]*url.LinkScope // 获取URL的范围，键为工作流的序号
+	nearDuplicate    bool                   // 存储，是否跳过内容近似重复的数据
+	nearDistance     int                    // 存储，认为近似的最大海明距离
+	nearFields       []string               // 存储，计算指纹的字段，默认全部字段
+	beforeRuleFunc   BeforeRuleFunc         // 规则，前置钩子函数，在匹配到URL后，处理前
+	afterRuleRunc    AfterRuleFunc          // 规则，后置钩子函数，在处理完绑定的方法后
+	saveFunc         SaveFunc               // 存储，存储函数
+	beforeSaveFunc   BeforeSaveFunc         // 存储，前置钩子函数
+	afterSaveFunc    AfterSaveFunc          // 存储，后置钩子函数
 }
 
 // BeforeRuleFunc 规则处理前置方法
@@ -49,6 +67,7 @@ func newRule(s string, t *Task) *Rule {
 	r.task = t
 	r.rule = s
 	r.re = re
+	r.stats = new(ruleStats)
 	return r
 }
 
@@ -64,7 +83,14 @@ func (r *Rule) Drop() *Rule {
 }
 
 // URLs 配置该配置的下一步是，抓取URL并加入到URL列表
-func (r *Rule) URLs() *Rule {
+// 可以设置链接的范围，只获取范围内的链接，新的URL带有当前页面的附加数据和 Referer
+func (r *Rule) URLs(scope ...*url.LinkScope) *Rule {
+	if len(scope) > 0 && scope[0] != nil {
+		if r.linkScopes == nil {
+			r.linkScopes = make(map[int]*url.LinkScope)
+		}
+		r.linkScopes[len(r.workflow)] = scope[0]
+	}
 	r.workflow = append(r.workflow, workFlowFetchURLs)
 	return r
 }
@@ -95,6 +121,14 @@ func (r *Rule) Save() *Rule {
 	return r
 }
 
+// Paginate 配置该配置的下一步是，获取下一页并加入到URL列表，下一页应符合该规则
+// 一般放在工作流的最后，根据已提取的数据判断是否没有数据
+func (r *Rule) Paginate(p *Pagination) *Rule {
+	r.workflow = append(r.workflow, workFlowPaginate)
+	r.pagination = p
+	return r
+}
+
 // Name 获取规则名称
 func (r *Rule) Name() string {
 	return r.name
@@ -118,12 +152,34 @@ func (r *Rule) ForceUpdate(v bool) *Rule {
 	return r
 }
 
+// SetRecrawl 设置符合规则的页面是否记录到任务的重新抓取调度器，默认记录
+func (r *Rule) SetRecrawl(v bool) *Rule {
+	r.noRecrawl = !v
+	return r
+}
+
 // SetExpand 展开单个复数字段，即：一个Row只有一个字段且该字段为数组时，展开该字段为多条数据
 func (r *Rule) SetExpand(v bool) *Rule {
 	r.expand = v
 	return r
 }
 
+// SkipNearDuplicate 设置跳过内容近似重复的数据，用字段计算 SimHash 指纹，与任务中保存过的数据比较，
+// 海明距离不超过 distance(一般为 3)时不保存，fields 为计算指纹的字段，默认全部字段，HTML标签被忽略
+func (r *Rule) SkipNearDuplicate(distance int, fields ...string) *Rule {
+	r.nearDuplicate = true
+	r.nearDistance = distance
+	r.nearFields = fields
+	return r
+}
+
+// SetErrorPolicy 设置字段出错时的策略，对未设置策略的字段生效，默认记录错误并保存数据
+// 策略见 url.ErrorPolicyIgnore, url.ErrorPolicyPartial, url.ErrorPolicyDrop, url.ErrorPolicyRetry, url.ErrorPolicyFail
+func (r *Rule) SetErrorPolicy(v int) *Rule {
+	r.errorPolicy = v
+	return r
+}
+
 // SetRuleFunc 设置规则的钩子函数
 func (r *Rule) SetRuleFunc(b BeforeRuleFunc, a AfterRuleFunc) *Rule {
 	r.beforeRuleFunc = b
@@ -152,6 +208,19 @@ func (r *Rule) Match(u string) bool {
 
 // Run 执行规则绑定的动作
 func (r *Rule) Run(u *url.URI, fetcher *FetcherPool) error {
+	atomic.AddInt64(&r.stats.runs, 1)
+	start := time.Now()
+	err := r.run(u, fetcher)
+	r.task.metrics.observeRule(r.task.id, r.name, time.Since(start), err)
+	if err != nil {
+		atomic.AddInt64(&r.stats.errors, 1)
+		r.task.stats.addError(r.name, u.URL, err)
+	}
+	return err
+}
+
+// run 执行规则的工作流
+func (r *Rule) run(u *url.URI, fetcher *FetcherPool) error {
 	var err error
 
 	// 前置方法
@@ -159,22 +228,48 @@ func (r *Rule) Run(u *url.URI, fetcher *FetcherPool) error {
 		r.beforeRuleFunc(r.task, u)
 	}
 
-	for _, w := range r.workflow {
+	links := 0 // 获取到的URL数量，用于分页判断是否没有数据
+	for i, w := range r.workflow {
 		if w == workFlowDrop {
 			break // drop
 		}
-		if err = r.fetch(u, fetcher); err != nil {
+		if err = r.fetch(u, fetcher); err == ErrFetchUnchanged {
+			r.task.Printf("页面内容没有变化 %s", u.URL)
+			return nil
+		}
+		if err == ErrFetchDeferred {
+			return nil
+		}
+		if err != nil {
 			log.Errorf("url fetch error: %s %v\n", u.URL, err)
 			return err
 		}
 		switch w {
 		case workFlowFetchURLs:
+			if scope, ok := r.linkScopes[i]; ok {
+				uris := u.FetchLinks(scope)
+				links += len(uris)
+				r.task.PushURI(uris...)
+				break
+			}
 			urls := u.FetchURLs()
+			links += len(urls)
 			r.task.PushURL(urls...)
 		case workFlowFetchRow:
-			r.parseRow(u)
+			if err = r.fetchRow(u, fetcher); err != nil {
+				log.Errorf("url fetch row error: %s %v\n", u.URL, err)
+				return err
+			}
 		case workFlowSave:
 			r.saveFields(u)
+		case workFlowPaginate:
+			empty := links == 0
+			if len(r.row) > 0 {
+				empty = u.FieldsEmpty()
+			}
+			if next := r.pagination.Next(u, empty); next != nil {
+				r.task.PushURI(next)
+			}
 		default:
 			// drop
 		}
@@ -196,6 +291,7 @@ func (r *Rule) SaveRow(u *url.URI, val map[string]interface{}) error {
 	}
 
 	if val == nil {
+		r.task.metrics.observeRow(r.task.id, r.name, rowDropped)
 		return Errorf("数据为空")
 	}
 
@@ -206,6 +302,8 @@ func (r *Rule) SaveRow(u *url.URI, val map[string]interface{}) error {
 			pk = v.(string)
 		case float64:
 			pk = strconv.FormatFloat(v.(float64), 'f', 0, 64)
+		case int64:
+			pk = strconv.FormatInt(v.(int64), 10)
 		}
 	}
 	if pk == "" {
@@ -215,11 +313,14 @@ func (r *Rule) SaveRow(u *url.URI, val map[string]interface{}) error {
 	// 保存数据
 	if r.saveFunc != nil {
 		if err := r.saveFunc(r.task.ID(), pk, val); err != nil {
+			r.task.metrics.observeRow(r.task.id, r.name, rowError)
 			return err
 		}
 	} else {
 		defaultSaveFunc(r.task.ID(), pk, val)
 	}
+	atomic.AddInt64(&r.stats.rows, 1)
+	r.task.metrics.observeRow(r.task.id, r.name, rowSaved)
 
 	// after
 	if r.afterSaveFunc != nil {
@@ -229,6 +330,16 @@ func (r *Rule) SaveRow(u *url.URI, val map[string]interface{}) error {
 	return nil
 }
 
+// Extract 对一个已获取内容的URI执行字段提取，返回数据行，不执行保存，可用于调试规则
+func (r *Rule) Extract(u *url.URI) map[string]interface{} {
+	u.PageType = r.pageType
+	if err := u.DecodeBody(); err != nil {
+		r.task.Printf("页面内容转换失败 %s: %v", u.URL, err)
+	}
+	r.parseRow(u)
+	return u.ExportFields()
+}
+
 // fetch 获取一个URI的数据并记录
 func (r *Rule) fetch(u *url.URI, fetcherPool *FetcherPool) error {
 	if u.Fetched {
@@ -251,14 +362,22 @@ func (r *Rule) fetch(u *url.URI, fetcherPool *FetcherPool) error {
 	// 反采集检测，无论如何都要执行，因为可能抓取错误就是反采集造成的
 	if r.task.setting.antiSpiderFunc != nil && r.task.setting.antiSpiderFunc(u) {
 		r.task.Printf("触发反采集策略 %s", u.URL)
+		if r.task.antiSpiderHit(u) {
+			return ErrFetchDeferred // 稍后重新抓取
+		}
 		return Errorf("触发反采集策略")
 	}
 	// 如果不是反采集错误，判断其他错误
 	if err != nil {
 		return err
 	}
+	r.task.antiSpiderPass(u)
 	// 记录URL
-	exists := r.task.logURL(u.URL, util.MD5Bytes(u.Body))
+	hash := util.MD5Bytes(u.Body)
+	exists := r.task.logURL(u.URL, hash)
+	if !r.noRecrawl && !r.task.logRecrawl(u, hash) && !r.forceUpdate {
+		return ErrFetchUnchanged // 重新抓取的内容没有变化
+	}
 	if r.forceUpdate == false && exists == true {
 		return nil // 无需处理，采集过了
 	}
@@ -269,23 +388,66 @@ func (r *Rule) fetch(u *url.URI, fetcherPool *FetcherPool) error {
 	return nil
 }
 
-// fields 提取该URL绑定的字段数据
+// fetchRow 提取数据行，字段出错时按策略重新抓取页面或终止任务
+func (r *Rule) fetchRow(u *url.URI, fetcherPool *FetcherPool) error {
+	for i := 0; ; i++ {
+		r.parseRow(u)
+		switch u.ErrorPolicy() {
+		case url.ErrorPolicyRetry:
+			if i >= r.task.setting.retryTimes {
+				return nil // 超过重试次数，保存时丢弃
+			}
+			r.task.Printf("字段提取失败，重新抓取页面 %s", u.URL)
+			u.ResetFields()
+			u.Fetched = false
+			if _, err := r.task.FetchURI(u, fetcherPool); err != nil {
+				return err
+			}
+		case url.ErrorPolicyFail:
+			return ErrRowFailed
+		default:
+			return nil
+		}
+	}
+}
+
+// parseRow 提取该URL绑定的字段数据，字段错误记录在URI中
 func (r *Rule) parseRow(u *url.URI) {
 	var err error
 	for i := range r.row {
 		f := r.row[i].Copy()
 		if err = f.Fetch(u); err != nil {
-			err = Errorf("字段[%s:%s]分析失败：%v", f.Name, f.Alias, err)
-			r.task.Print(err)
+			r.addFieldError(u, &url.FieldError{Field: f.Name, Err: err, Policy: f.ErrorPolicy()})
 		}
 		// 执行全局字段过滤器，PS. 字段本身的过滤器已经优先执行
 		if err == nil {
 			r.filterField(f)
 		}
+		// 按字段类型转换值，使用默认值，检查必填，每个校验错误单独记录
+		if verr, ok := f.Validate().(url.ValidationErrors); ok {
+			for _, e := range verr {
+				r.addFieldError(u, &url.FieldError{Field: e.Field, Err: e.Err, Policy: e.Policy})
+			}
+		}
 		u.AddFields(f)
 	}
 }
 
+// addFieldError 记录字段错误，未设置策略的字段使用规则的策略，并执行字段错误钩子
+func (r *Rule) addFieldError(u *url.URI, e *url.FieldError) {
+	if e.Policy == url.ErrorPolicyInherit {
+		e.Policy = r.errorPolicy
+	}
+	if e.Policy == url.ErrorPolicyInherit {
+		e.Policy = url.ErrorPolicyIgnore
+	}
+	r.task.Printf("%s %v", u.URL, e)
+	u.AddFieldError(e)
+	if r.task.setting.fieldErrorFunc != nil {
+		r.task.setting.fieldErrorFunc(r, u, e)
+	}
+}
+
 // filterField 对字段执行过滤，含子字段
 func (r *Rule) filterField(f *url.Field) {
 	if len(r.fieldFilterFuncs) == 0 {
@@ -310,15 +472,20 @@ func (r *Rule) filterField(f *url.Field) {
 // saveFields 执行保存字段的动作，如果数据为空，跳过保存
 // 允许before方法修复要保存的数据
 func (r *Rule) saveFields(u *url.URI) {
+	// 字段错误策略
+	policy := u.ErrorPolicy()
+	if policy >= url.ErrorPolicyDrop {
+		r.task.Printf("数据行有字段错误，丢弃 %s", u.URL)
+		r.task.metrics.observeRow(r.task.id, r.name, rowDropped)
+		return
+	}
 	v := u.ExportFields()
 	// 如果只有一个字段，并且设置了展开，那么久展开为多条数据
 	if r.expand && len(v) == 1 {
 		for _, v := range v {
 			if array, ok := v.([]map[string]interface{}); ok {
 				for i := range array {
-					if err := r.SaveRow(u, array[i]); err != nil {
-						log.Errorf("Rule.saveFields error: %s %v\n", u.URL, err)
-					}
+					r.saveRow(u, array[i], policy)
 				}
 			}
 			break
@@ -328,9 +495,70 @@ func (r *Rule) saveFields(u *url.URI) {
 
 	// 不展开，直接保存这条数据
 	if v != nil {
-		if err := r.SaveRow(u, v); err != nil {
-			log.Errorf("Rule.saveFields error: %s %v\n", u.URL, err)
+		r.saveRow(u, v, policy)
+	}
+
+}
+
+// saveRow 保存一条数据行，跳过近似重复的数据，保存成功后才记录内容指纹，
+// 保存失败或被丢弃的数据不影响之后相同内容的保存
+func (r *Rule) saveRow(u *url.URI, row map[string]interface{}, policy int) {
+	fp := r.fingerprint(row)
+	if fp != 0 {
+		if _, ok := r.task.simIndex(r.nearDistance).Near(fp); ok {
+			r.task.Printf("数据行内容近似重复，跳过 %s", u.URL)
+			r.task.metrics.observeRow(r.task.id, r.name, rowDuplicate)
+			return
+		}
+	}
+	if policy == url.ErrorPolicyPartial {
+		markPartial(u, row)
+	}
+	if err := r.SaveRow(u, row); err != nil {
+		log.Errorf("Rule.saveFields error: %s %v\n", u.URL, err)
+		return
+	}
+	if fp != 0 {
+		r.task.simIndex(r.nearDistance).Add(fp)
+	}
+}
+
+// htmlTagRe 匹配HTML标签，计算指纹时忽略
+var htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// fingerprint 计算数据行的内容指纹，没有设置跳过近似重复的数据或内容为空时返回 0
+func (r *Rule) fingerprint(row map[string]interface{}) uint64 {
+	if !r.nearDuplicate {
+		return 0
+	}
+	names := r.nearFields
+	if len(names) == 0 {
+		for name := range row {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	texts := make([]string, 0, len(names))
+	for _, name := range names {
+		v, ok := row[name]
+		if !ok || v == nil {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
 		}
+		texts = append(texts, htmlTagRe.ReplaceAllString(s, " "))
 	}
+	return util.SimHash(strings.Join(texts, "\n"))
+}
 
+// markPartial 标记数据行为部分数据，附加字段错误
+func markPartial(u *url.URI, val map[string]interface{}) {
+	errs := make([]string, 0, len(u.FieldErrors()))
+	for _, e := range u.FieldErrors() {
+		errs = append(errs, e.Error())
+	}
+	val[RowPartialKey] = true
+	val[RowErrorsKey] = errs
 }
@@ -6,6 +6,13 @@ import (
 	"github.com/safeie/spider/component/url"
 )
 
+const (
+	// RowPartialKey 部分数据的标记字段，字段错误策略为 url.ErrorPolicyPartial 时附加到数据行
+	RowPartialKey = "_partial"
+	// RowErrorsKey 部分数据的字段错误列表
+	RowErrorsKey = "_errors"
+)
+
 // SaveFunc 存储方法
 type SaveFunc func(taskID, pk string, val map[string]interface{}) error
 
@@ -15,6 +22,9 @@ type BeforeSaveFunc func(rule *Rule, uri *url.URI, val map[string]interface{}) m
 // AfterSaveFunc 存储后置方法
 type AfterSaveFunc func(rule *Rule, uri *url.URI)
 
+// FieldErrorFunc 字段错误钩子，每个字段错误执行一次，可用于统计
+type FieldErrorFunc func(rule *Rule, uri *url.URI, err *url.FieldError)
+
 // BeforeQuitFunc 退出前置方法
 type BeforeQuitFunc func(taskID string, queue []string)
 
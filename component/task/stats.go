@@ -0,0 +1,120 @@
+package task
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// recentErrorSize 保留的最近错误数量
+const recentErrorSize = 100
+
+// Stats 任务的运行状态和统计，统计从任务创建时开始累计
+type Stats struct {
+	ID          string      `json:"id"`           // 任务编号
+	Name        string      `json:"name"`         // 任务名称
+	Running     bool        `json:"running"`      // 是否在运行
+	Paused      bool        `json:"paused"`       // 是否暂停
+	PausedUntil time.Time   `json:"paused_until"` // 暂停到的时间，零值表示直到继续
+	Queue       int         `json:"queue"`        // 队列中的URL数量
+	Active      int         `json:"active"`       // 执行中的抓取协程数量
+	Interval    int         `json:"interval"`     // 执行间隔，单位 毫秒
+	RoutineNum  int         `json:"routine_num"`  // 协程数量
+	Fetches     int64       `json:"fetches"`      // 抓取的次数
+	FetchErrors int64       `json:"fetch_errors"` // 抓取失败的次数
+	AntiSpider  int64       `json:"anti_spider"`  // 触发反采集策略的次数
+	ErrorRate   float64     `json:"error_rate"`   // 抓取失败的比例
+	Proxy       string      `json:"proxy"`        // 最近一次使用的代理，没有使用代理时为空
+	Rules       []RuleStats `json:"rules"`        // 每个规则的统计
+}
+
+// RuleStats 规则的统计
+type RuleStats struct {
+	Name   string `json:"name"`   // 规则名称
+	Rule   string `json:"rule"`   // 规则的字面
+	Runs   int64  `json:"runs"`   // 处理的URL数量
+	Errors int64  `json:"errors"` // 处理出错的URL数量
+	Rows   int64  `json:"rows"`   // 保存的数据行
+}
+
+// ErrorRecord 一条错误记录
+type ErrorRecord struct {
+	Time  time.Time `json:"time"`  // 时间
+	Rule  string    `json:"rule"`  // 规则名称
+	URL   string    `json:"url"`   // URL
+	Error string    `json:"error"` // 错误信息
+}
+
+// taskStats 任务的计数器
+type taskStats struct {
+	fetches     int64
+	fetchErrors int64
+	antiSpider  int64
+	errors      []ErrorRecord // 最近的错误，环形保存
+	next        int           // 下一条错误的位置
+	mu          sync.Mutex
+}
+
+// ruleStats 规则的计数器
+type ruleStats struct {
+	runs   int64
+	errors int64
+	rows   int64
+}
+
+// addError 记录一条错误
+func (s *taskStats) addError(rule, url string, err error) {
+	e := ErrorRecord{Time: time.Now(), Rule: rule, URL: url, Error: err.Error()}
+	s.mu.Lock()
+	if len(s.errors) < recentErrorSize {
+		s.errors = append(s.errors, e)
+	} else {
+		s.errors[s.next] = e
+	}
+	s.next = (s.next + 1) % recentErrorSize
+	s.mu.Unlock()
+}
+
+// Stats 返回任务的运行状态和统计
+func (t *Task) Stats() Stats {
+	s := Stats{
+		ID:          t.id,
+		Name:        t.name,
+		Running:     t.IsRunning(),
+		Paused:      t.IsPaused(),
+		PausedUntil: t.PausedUntil(),
+		Queue:       t.url.Len(),
+		Active:      t.Active(),
+		Interval:    t.Interval(),
+		RoutineNum:  t.RoutineNum(),
+		Fetches:     atomic.LoadInt64(&t.stats.fetches),
+		FetchErrors: atomic.LoadInt64(&t.stats.fetchErrors),
+		AntiSpider:  atomic.LoadInt64(&t.stats.antiSpider),
+		Proxy:       t.setting.fetchOption.GetUsedProxy(),
+	}
+	if s.Fetches > 0 {
+		s.ErrorRate = float64(s.FetchErrors) / float64(s.Fetches)
+	}
+	for _, r := range t.rule {
+		s.Rules = append(s.Rules, RuleStats{
+			Name:   r.name,
+			Rule:   r.rule,
+			Runs:   atomic.LoadInt64(&r.stats.runs),
+			Errors: atomic.LoadInt64(&r.stats.errors),
+			Rows:   atomic.LoadInt64(&r.stats.rows),
+		})
+	}
+	return s
+}
+
+// RecentErrors 返回最近的错误，最新的在前，最多保留100条
+func (t *Task) RecentErrors() []ErrorRecord {
+	t.stats.mu.Lock()
+	defer t.stats.mu.Unlock()
+	n := len(t.stats.errors)
+	list := make([]ErrorRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		list = append(list, t.stats.errors[(t.stats.next-i+n)%n])
+	}
+	return list
+}
@@ -2,11 +2,15 @@
 package task
 
 import (
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/safeie/spider/common/log"
+	"github.com/safeie/spider/component/dedup"
 	"github.com/safeie/spider/component/fetcher"
+	"github.com/safeie/spider/component/recrawl"
 	"github.com/safeie/spider/component/url"
 )
 
@@ -16,39 +20,69 @@ const (
 	stopAndSaveQueue     // 停止并保存队列
 )
 
+// maxRoutineNum 最大的协程数量
+const maxRoutineNum = 1000
+
+// recrawlBatch 每次加入队列的到期URL的最大数量
+const recrawlBatch = 1000
+
+// antiSpiderKey 附加数据中触发反采集策略后重新抓取的次数
+const antiSpiderKey = "_anti_spider"
+
 // Task 任务
 type Task struct {
-	id          string        // 任务编号
-	name        string        // 任务名称
-	domain      string        // 该任务匹配的域名
-	configDir   string        // 配置目录，用于获取配置脚本
-	logger      log.StdLogger // 日志器
-	url         *url.URL      // URL管理器
-	fetcherPool *FetcherPool  // 抓取器
-	rule        []*Rule       // 采集规则
-	setting     *taskSetting  // 设置项
-	lockrunning sync.RWMutex  // 任务运行锁
-	running     bool          // 任务是否在运行中
-	routineNum  int           // 协程数量，默认10个
-	chanLink    chan struct{} // 控制抓取的协程通道
-	shutdown    chan int      // 关闭，根据传递的信号决定是否保存队列
-	urlStore    *sync.Map     // url存储器，用来判断是否已经采集过
+	id          string                  // 任务编号
+	name        string                  // 任务名称
+	domain      string                  // 该任务匹配的域名
+	configDir   string                  // 配置目录，用于获取配置脚本
+	logger      log.StdLogger           // 日志器
+	url         *url.URL                // URL管理器
+	fetcherPool *FetcherPool            // 抓取器
+	rule        []*Rule                 // 采集规则
+	setting     *taskSetting            // 设置项
+	lockrunning sync.RWMutex            // 任务运行锁
+	running     bool                    // 任务是否在运行中
+	routineNum  int                     // 协程数量，默认10个
+	chanLink    chan struct{}           // 控制抓取的协程通道
+	shutdown    chan int                // 关闭，根据传递的信号决定是否保存队列
+	simIndexes  map[int]*dedup.SimIndex // 内容指纹索引，键为海明距离，用于跳过近似重复的数据
+	locksim     sync.Mutex              // 指纹索引锁
+	paused      bool                    // 是否暂停
+	pauseUntil  time.Time               // 暂停到的时间，零值表示直到调用 Resume
+	antiHits    int                     // 连续触发反采集策略的次数
+	lockpause   sync.Mutex              // 暂停锁
+	stats       *taskStats              // 统计
+	metrics     *taskMetrics            // 指标，没有设置注册表时为 nil
+	fetched     dedup.Store             // 抓取记录，键为内容摘要和URL，Reset 不清空
 }
 
 // taskSetting 任务设置项目，可外部设置的内容
 type taskSetting struct {
-	fetchOption     *fetcher.Option // 抓取，配置
-	engine          int             // 抓取，抓取引擎
-	interval        int             // 执行间隔，单位 毫秒，用于限制采集频率
-	autoSession     bool            // 是否自动记录会话
-	errorContinue   bool            // 出错后，是否继续下一个URL
-	retryTimes      int             // 出错，重试次数
-	prepareFunc     PrepareFunc     // 任务，预处理钩子函数
-	antiSpiderFunc  AntiSpiderFunc  // 抓取，反作弊函数
-	checkRepeatFunc CheckRepeatFunc // 抓取，重复检测钩子函数
-	beforeFetchFunc BeforeFetchFunc // 抓取，前置钩子函数
-	afterFetchFunc  AfterFetchFunc  // 抓取，后置钩子函数
-	beforeQuitFunc  BeforeQuitFunc  // 停止，前置钩子函数
+	fetchOption     *fetcher.Option    // 抓取，配置
+	engine          int                // 抓取，抓取引擎
+	interval        int                // 执行间隔，单位 毫秒，用于限制采集频率
+	autoSession     bool               // 是否自动记录会话
+	errorContinue   bool               // 出错后，是否继续下一个URL
+	retryTimes      int                // 出错，重试次数
+	prepareFunc     PrepareFunc        // 任务，预处理钩子函数
+	antiSpiderFunc  AntiSpiderFunc     // 抓取，反作弊函数
+	checkRepeatFunc CheckRepeatFunc    // 抓取，重复检测钩子函数
+	beforeFetchFunc BeforeFetchFunc    // 抓取，前置钩子函数
+	afterFetchFunc  AfterFetchFunc     // 抓取，后置钩子函数
+	beforeQuitFunc  BeforeQuitFunc     // 停止，前置钩子函数
+	fieldErrorFunc  FieldErrorFunc     // 字段，错误钩子函数
+	recrawl         *recrawl.Scheduler // 重新抓取调度器，设置后任务不会因队列为空退出
+	limiter         Limiter            // 共用的并发限制
+	antiThreshold   int                // 连续触发反采集策略多少次后自动暂停，0 不暂停
+	antiCooldown    time.Duration      // 自动暂停的时长
+}
+
+// Limiter 并发限制，多个任务共用时限制总的抓取协程数
+type Limiter interface {
+	// TryAcquire 获取一个协程的配额，没有空闲时返回 false，不阻塞
+	TryAcquire() bool
+	// Release 归还一个协程的配额
+	Release()
 }
 
 // PrepareFunc 任务预处理函数
@@ -65,9 +99,13 @@ func New(taskID, taskName, domain, configDir string) *Task {
 	t.configDir = configDir
 	t.logger = log.DefaultLogger
 	t.url = url.NewURL()
+	t.fetched = dedup.NewMap()
 	t.rule = make([]*Rule, 0, 2)
 	t.routineNum = 10
 	t.shutdown = make(chan int)
+	// 控制抓取的协程数，运行中可以修改协程数量，通道按最大数量创建，只创建一次，运行中可以并发读取
+	t.chanLink = make(chan struct{}, maxRoutineNum)
+	t.stats = new(taskStats)
 
 	t.setting = new(taskSetting)
 	t.setting.interval = 100 // 0.1秒
@@ -78,13 +116,10 @@ func New(taskID, taskName, domain, configDir string) *Task {
 	// 抓取设置
 	t.setting.fetchOption = fetcher.NewOption(t.configDir)
 
-	// 初始化存储
-	t.urlStore = new(sync.Map)
-
 	return t
 }
 
-// Reset 复用
+// Reset 复用，清空本次运行抓取过的URL，抓取记录和保存到文件的存储保留
 func (t *Task) Reset() *Task {
 	t.url.Reset()
 	return t
@@ -130,10 +165,27 @@ func (t *Task) SetRoutineNum(v int) *Task {
 	if v == 0 {
 		v = 10
 	}
+	if v > maxRoutineNum {
+		v = maxRoutineNum
+	}
+	t.lockrunning.Lock()
 	t.routineNum = v
+	t.lockrunning.Unlock()
 	return t
 }
 
+// RoutineNum 返回协程数量
+func (t *Task) RoutineNum() int {
+	t.lockrunning.RLock()
+	defer t.lockrunning.RUnlock()
+	return t.routineNum
+}
+
+// Active 返回执行中的抓取协程数量
+func (t *Task) Active() int {
+	return len(t.chanLink)
+}
+
 // EnableJS 是否启用JS渲染
 func (t *Task) EnableJS(ok bool) *Task {
 	if ok {
@@ -147,10 +199,19 @@ func (t *Task) EnableJS(ok bool) *Task {
 
 // SetInterval 设置采集间隔，单位 微妙，默认 100微秒
 func (t *Task) SetInterval(v int) *Task {
+	t.lockrunning.Lock()
 	t.setting.interval = v
+	t.lockrunning.Unlock()
 	return t
 }
 
+// Interval 返回执行间隔，单位 毫秒
+func (t *Task) Interval() int {
+	t.lockrunning.RLock()
+	defer t.lockrunning.RUnlock()
+	return t.setting.interval
+}
+
 // SetAutoSession 设置是否自动记录会话
 // 比如，雪球网，必须先访问一下HTML页面记录下会话才可以继续请求JSON数据
 // 比如，豆瓣网，根据cookie会话统计访问频次，不能记录cookie
@@ -261,6 +322,82 @@ func (t *Task) SetAntiSpiderFunc(f AntiSpiderFunc) *Task {
 	return t
 }
 
+// SetFieldErrorFunc 设置字段错误钩子函数，每个字段错误执行一次
+func (t *Task) SetFieldErrorFunc(f FieldErrorFunc) *Task {
+	t.setting.fieldErrorFunc = f
+	return t
+}
+
+// SetCanonicalizer 设置URL规范化，用于URL列表和抓取记录判断重复，nil 时使用原始的URL判断
+// 默认使用 url.NewCanonicalizer()
+func (t *Task) SetCanonicalizer(c *url.Canonicalizer) *Task {
+	t.url.SetCanonicalizer(c)
+	return t
+}
+
+// SetDedupStore 设置URL列表判断重复的存储，默认为内存存储
+// 使用 dedup.OpenBloom 或 dedup.OpenDisk 可以减少内存并在多次运行之间保留，任务结束时保存，由调用方关闭，
+// 自定义的存储实现 dedup.Persister 并返回 true 时，任务重置时不清空
+func (t *Task) SetDedupStore(s dedup.Store) *Task {
+	t.url.SetStore(s)
+	return t
+}
+
+// SetFetchedStore 设置抓取记录的存储，记录URL和内容摘要，内容没有变化的页面不再处理，默认为内存存储
+// Reset 不清空抓取记录，任务结束时保存，由调用方关闭
+func (t *Task) SetFetchedStore(s dedup.Store) *Task {
+	t.fetched = s
+	return t
+}
+
+// simIndex 返回任务中指定海明距离的指纹索引，不存在时创建
+func (t *Task) simIndex(distance int) *dedup.SimIndex {
+	t.locksim.Lock()
+	defer t.locksim.Unlock()
+	if t.simIndexes == nil {
+		t.simIndexes = make(map[int]*dedup.SimIndex)
+	}
+	x, ok := t.simIndexes[distance]
+	if !ok {
+		x = dedup.NewSimIndex(distance)
+		t.simIndexes[distance] = x
+	}
+	return x
+}
+
+// SetRecrawl 设置重新抓取调度器，记录抓取过的页面的时间和内容摘要，到期后重新抓取，内容没有变化时跳过后续的处理
+// 设置后任务会一直运行，队列为空时等待到期的URL，直到调用 Stop 或 Close，任务结束时保存，由调用方关闭
+// 带有请求参数的URI(如分页的 POST 请求)无法只凭URL重新抓取，不会记录
+func (t *Task) SetRecrawl(s *recrawl.Scheduler) *Task {
+	t.setting.recrawl = s
+	return t
+}
+
+// Recrawl 返回重新抓取调度器，没有设置时为 nil
+func (t *Task) Recrawl() *recrawl.Scheduler {
+	return t.setting.recrawl
+}
+
+// SetAntiSpiderPause 设置连续 threshold 次触发反采集策略后自动暂停 cooldown，之后自动继续
+// 设置后触发反采集策略的URL重新加入队列，最多重试 SetRetryTimes 次，不会因此终止任务
+func (t *Task) SetAntiSpiderPause(threshold int, cooldown time.Duration) *Task {
+	t.setting.antiThreshold = threshold
+	t.setting.antiCooldown = cooldown
+	return t
+}
+
+// SetLimiter 设置共用的并发限制，抓取协程数同时受 SetRoutineNum 和 Limiter 限制
+func (t *Task) SetLimiter(l Limiter) *Task {
+	t.setting.limiter = l
+	return t
+}
+
+// SetTransport 设置共用的HTTP传输，多个任务共用连接池和代理
+func (t *Task) SetTransport(rt http.RoundTripper) *Task {
+	t.setting.fetchOption.SetTransport(rt)
+	return t
+}
+
 // SetURLinitFunc 设置URL初始化函数
 func (t *Task) SetURLinitFunc(f url.URLinitFunc) *Task {
 	t.url.SetInitFunc(f)
@@ -281,9 +418,18 @@ func (t *Task) Rule(s string) *Rule {
 	return r
 }
 
+// Rules 返回任务的全部规则
+func (t *Task) Rules() []*Rule {
+	return t.rule
+}
+
 // FetchURL 获取单个网页，给外部调用
 func (t *Task) FetchURL(uri string) (u *url.URI, cookie string, err error) {
 	u = url.NewURI(uri)
+	// 任务未运行时，没有初始化抓取器
+	if t.fetcherPool == nil {
+		t.fetcherPool = NewFetcherPool(1, 0, t.setting.engine, t)
+	}
 	cookie, err = t.FetchURI(u, t.fetcherPool)
 	return u, cookie, err
 }
@@ -292,8 +438,11 @@ func (t *Task) FetchURL(uri string) (u *url.URI, cookie string, err error) {
 func (t *Task) FetchURI(u *url.URI, fetcherPool *FetcherPool) (string, error) {
 	var err error
 	var res *fetcher.Response
+	retries := 0
+	start := time.Now()
 	f := fetcherPool.Get()
 	for i := 0; i < t.setting.retryTimes; i++ {
+		retries = i
 		res, err = f.Fetch(u.URL, u.Req.Params, u.Req.Header)
 		if res != nil && (res.Code == 404 || res.Code == 403) {
 			break
@@ -303,7 +452,10 @@ func (t *Task) FetchURI(u *url.URI, fetcherPool *FetcherPool) (string, error) {
 		}
 	}
 	fetcherPool.Put(f)
+	t.ObserveFetch("page", u.URL, t.setting.engine, res, retries, time.Since(start), err)
+	atomic.AddInt64(&t.stats.fetches, 1)
 	if err != nil {
+		atomic.AddInt64(&t.stats.fetchErrors, 1)
 		t.Printf("页面抓取失败 %s: %v", u.URL, err)
 	} else {
 		t.Printf("页面抓取成功 %s", u.URL)
@@ -320,10 +472,16 @@ func (t *Task) FetchURI(u *url.URI, fetcherPool *FetcherPool) (string, error) {
 
 	// 设置抓取过
 	u.Code = res.Code
-	u.Body = res.Body
+	u.ResetBody(res.Body) // 清空上次抓取的解析器，重新抓取时按新的内容解析
 	u.Header = res.Header
 	u.Fetched = true
 
+	// 文档类型的内容转为文本
+	if err = u.DecodeBody(); err != nil {
+		t.Printf("页面内容转换失败 %s: %v", u.URL, err)
+		return "", err
+	}
+
 	return res.Cookie, nil
 }
 
@@ -364,7 +522,7 @@ func (t *Task) runRule(uri *url.URI, fetcherPool *FetcherPool) {
 	if rules := t.matchRule(uri.URL); rules != nil {
 		for _, r := range rules {
 			if err = r.Run(uri, fetcherPool); err != nil {
-				if !t.setting.errorContinue {
+				if !t.setting.errorContinue || err == ErrRowFailed {
 					t.Printf("任务遇到错误即将终止: %v", err)
 					go func() {
 						t.Stop()
@@ -379,7 +537,7 @@ func (t *Task) runRule(uri *url.URI, fetcherPool *FetcherPool) {
 // Run 开始执行
 func (t *Task) Run() error {
 	// 运行中，禁止修改
-	if t.isRunning() {
+	if t.IsRunning() {
 		return Errorf("Task is running...")
 	}
 	t.setRunning(true)
@@ -390,24 +548,26 @@ func (t *Task) Run() error {
 	// 初始化URL控制器
 	t.url.Initialize()
 	// 初始化抓取器
-	t.fetcherPool = NewFetcherPool(t.routineNum, 0, t.setting.engine, t)
-	// 控制抓取的协程数
-	t.chanLink = make(chan struct{}, t.routineNum)
+	t.fetcherPool = NewFetcherPool(t.RoutineNum(), 0, t.setting.engine, t)
 	// 运行prepare函数
 	if t.setting.prepareFunc != nil {
 		t.setting.prepareFunc(t)
 	}
 
-	// 开启种子协程
+	// 开启种子协程，入口URL不判断重复
 	for _, u := range t.url.GetInitURLs() {
 		go func(u string) {
-			t.PushURL(u)
+			if rule := t.matchRule(u); rule != nil {
+				t.url.PushInitURL(u)
+			}
 		}(u)
 	}
 
 	// 开启主进程
 	shutdown := 0
-	ticker := time.NewTicker(time.Millisecond * time.Duration(t.setting.interval))
+	dueAt := time.Time{} // 上次检查到期URL的时间
+	interval := t.Interval()
+	ticker := time.NewTicker(time.Millisecond * time.Duration(interval))
 	for {
 		select {
 		case ch := <-t.shutdown:
@@ -415,6 +575,12 @@ func (t *Task) Run() error {
 		case <-ticker.C:
 			// get a ticket
 		}
+		// 运行中修改了执行间隔
+		if v := t.Interval(); v != interval && v > 0 {
+			interval = v
+			ticker.Stop()
+			ticker = time.NewTicker(time.Millisecond * time.Duration(interval))
+		}
 		if shutdown > 0 {
 			// 停止服务
 			if shutdown == stopAndSaveQueue {
@@ -426,8 +592,21 @@ func (t *Task) Run() error {
 			break
 		}
 
-		// 没有任务了，退出
-		if t.url.Len() == 0 && len(t.chanLink) == 0 {
+		// 暂停时不取出URL，等待执行中的抓取完成
+		if t.IsPaused() {
+			continue
+		}
+
+		// 队列为空时，加入到期需要重新抓取的URL，每秒最多检查一次
+		if t.url.Len() == 0 && t.setting.recrawl != nil && time.Since(dueAt) >= time.Second {
+			dueAt = time.Now()
+			for _, u := range t.setting.recrawl.Due(dueAt, recrawlBatch) {
+				t.url.PushDueURL(u)
+			}
+		}
+
+		// 没有任务了，退出，设置了重新抓取时等待到期的URL
+		if t.url.Len() == 0 && len(t.chanLink) == 0 && t.setting.recrawl == nil {
 			break
 		}
 
@@ -436,8 +615,22 @@ func (t *Task) Run() error {
 			continue
 		}
 
+		// 抓取协程已满，等待下一次
+		if len(t.chanLink) >= t.RoutineNum() {
+			continue
+		}
+
+		// 共用的并发限制，没有空闲的配额时等待下一次
+		limiter := t.setting.limiter
+		if limiter != nil && !limiter.TryAcquire() {
+			continue
+		}
+
 		u := t.url.Pop()
 		if u == nil || u.URL == "" || u.Fetched {
+			if limiter != nil {
+				limiter.Release()
+			}
 			continue
 		}
 
@@ -446,29 +639,129 @@ func (t *Task) Run() error {
 		go func(u *url.URI, ch chan struct{}, fetcherPool *FetcherPool) {
 			t.runRule(u, fetcherPool)
 			<-ch
+			if limiter != nil {
+				limiter.Release()
+			}
 		}(u, t.chanLink, t.fetcherPool)
 	}
 
-	// 执行结束
+	// 执行结束，保存判断重复的存储
+	if err := t.url.Store().Sync(); err != nil {
+		t.Printf("保存重复判断存储失败: %v", err)
+	}
+	if err := t.fetched.Sync(); err != nil {
+		t.Printf("保存抓取记录失败: %v", err)
+	}
+	if t.setting.recrawl != nil {
+		if err := t.setting.recrawl.Sync(); err != nil {
+			t.Printf("保存重新抓取记录失败: %v", err)
+		}
+	}
 	return nil
 }
 
+// Pause 暂停任务，不再从队列中取出URL，执行中的抓取继续完成，队列和抓取记录保留在内存中
+// 暂停的任务不会因队列为空退出，直到调用 Resume，Stop 或 Close
+func (t *Task) Pause() {
+	t.lockpause.Lock()
+	t.paused = true
+	t.pauseUntil = time.Time{}
+	t.lockpause.Unlock()
+}
+
+// PauseFor 暂停任务 d 时长，之后自动继续
+func (t *Task) PauseFor(d time.Duration) {
+	t.lockpause.Lock()
+	t.paused = true
+	t.pauseUntil = time.Now().Add(d)
+	t.lockpause.Unlock()
+}
+
+// Resume 继续暂停的任务
+func (t *Task) Resume() {
+	t.lockpause.Lock()
+	t.paused = false
+	t.pauseUntil = time.Time{}
+	t.antiHits = 0
+	t.lockpause.Unlock()
+}
+
+// IsPaused 判断任务是否暂停，暂停时间到了时自动继续
+func (t *Task) IsPaused() bool {
+	t.lockpause.Lock()
+	defer t.lockpause.Unlock()
+	if t.paused && !t.pauseUntil.IsZero() && !time.Now().Before(t.pauseUntil) {
+		t.paused = false
+		t.pauseUntil = time.Time{}
+		t.antiHits = 0
+		t.Printf("任务暂停结束，继续执行")
+	}
+	return t.paused
+}
+
+// PausedUntil 返回暂停到的时间，没有暂停或暂停直到调用 Resume 时为零值
+func (t *Task) PausedUntil() time.Time {
+	t.lockpause.Lock()
+	defer t.lockpause.Unlock()
+	return t.pauseUntil
+}
+
+// antiSpiderHit 记录一次触发反采集策略，连续次数达到设置时自动暂停，URL重新加入队列
+// 没有设置自动暂停时返回 false
+func (t *Task) antiSpiderHit(u *url.URI) bool {
+	atomic.AddInt64(&t.stats.antiSpider, 1)
+	t.metrics.observeAntiSpider(t.id)
+	if t.setting.antiThreshold <= 0 {
+		return false
+	}
+	t.lockpause.Lock()
+	t.antiHits++
+	if t.antiHits >= t.setting.antiThreshold && !t.paused {
+		t.paused = true
+		t.pauseUntil = time.Now().Add(t.setting.antiCooldown)
+		t.Printf("连续 %d 次触发反采集策略，暂停 %s", t.antiHits, t.setting.antiCooldown)
+	}
+	t.lockpause.Unlock()
+
+	times, _ := u.Get(antiSpiderKey).(int)
+	if times >= t.setting.retryTimes {
+		t.Printf("触发反采集策略超过重试次数，丢弃 %s", u.URL)
+		return true
+	}
+	n := u.Copy()
+	n.Fetched = false
+	n.Set(antiSpiderKey, times+1)
+	t.url.Requeue(n)
+	return true
+}
+
+// antiSpiderPass 抓取没有触发反采集策略，清零连续次数和URI的重试次数
+func (t *Task) antiSpiderPass(u *url.URI) {
+	u.Delete(antiSpiderKey)
+	if t.setting.antiThreshold <= 0 {
+		return
+	}
+	t.lockpause.Lock()
+	t.antiHits = 0
+	t.lockpause.Unlock()
+}
+
 // Stop 停止任务，保存队列
 func (t *Task) Stop() {
-	if t.isRunning() {
+	if t.IsRunning() {
 		t.shutdown <- stopAndSaveQueue
 	}
 }
 
 // Close 关闭任务，直接关闭不保存队列
 func (t *Task) Close() {
-	if t.isRunning() {
+	if t.IsRunning() {
 		t.shutdown <- stopNotSaveQueue
 	}
 }
 
-// isRunning 判断任务是否在运行
-func (t *Task) isRunning() bool {
+// IsRunning 判断任务是否在运行
+func (t *Task) IsRunning() bool {
 	t.lockrunning.RLock()
 	running := t.running
 	t.lockrunning.RUnlock()
@@ -510,15 +803,16 @@ func (t *Task) save() error {
 	return nil
 }
 
-// logURL 记录url用于重复判断 如果已存在返回 true
-func (t *Task) logURL(url, hash string) bool {
-	v, ok := t.urlStore.Load(url)
-	if ok {
-		if hash == v.(string) {
-			return true
-		}
+// logRecrawl 记录URL的抓取时间和内容摘要，返回内容是否变化，没有设置重新抓取时总是变化
+func (t *Task) logRecrawl(u *url.URI, hash string) bool {
+	if t.setting.recrawl == nil || len(u.Req.Params) > 0 {
+		return true
 	}
+	return t.setting.recrawl.Update(t.url.Canonical(u.URL), u.URL, hash, time.Now())
+}
 
-	t.urlStore.Store(url, hash)
-	return false
+// logURL 记录url用于重复判断 如果已存在并且内容相同返回 true，使用规范化的URL
+// 保存在抓取记录中，键为 内容摘要 + 空格 + URL
+func (t *Task) logURL(url, hash string) bool {
+	return t.fetched.Add(hash + " " + t.url.Canonical(url))
 }
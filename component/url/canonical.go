@@ -0,0 +1,185 @@
+package url
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultStripParams 默认去掉的跟踪参数
+var DefaultStripParams = []string{"utm_*", "gclid", "fbclid", "msclkid", "spm"}
+
+// Canonicalizer URL规范化，用于判断重复，规范化后相同的URL只抓取一次
+// 规范化只用于判断重复，抓取时仍使用原始的URL
+type Canonicalizer struct {
+	sortParams     bool             // 按名称排序查询参数
+	stripParams    []*regexp.Regexp // 去掉的查询参数
+	lowerHost      bool             // 协议和主机转为小写，去掉默认端口
+	removeFragment bool             // 去掉锚点
+	resolvePath    bool             // 处理路径中的 . 和 ..
+	trailingSlash  bool             // 去掉路径末尾的斜杠，根路径除外
+}
+
+// NewCanonicalizer 创建一个URL规范化，默认排序查询参数，去掉跟踪参数(DefaultStripParams)，
+// 协议和主机转为小写，去掉默认端口和锚点，处理路径中的 . 和 ..，保留路径末尾的斜杠
+func NewCanonicalizer() *Canonicalizer {
+	c := new(Canonicalizer)
+	c.sortParams = true
+	c.lowerHost = true
+	c.removeFragment = true
+	c.resolvePath = true
+	c.SetStripParams(DefaultStripParams...)
+	return c
+}
+
+// SetSortParams 设置是否按名称排序查询参数，?b=2&a=1 与 ?a=1&b=2 相同，
+// 同名参数保持原来的顺序，?a=1&a=0 与 ?a=0&a=1 不同
+func (c *Canonicalizer) SetSortParams(v bool) *Canonicalizer {
+	c.sortParams = v
+	return c
+}
+
+// SetStripParams 设置去掉的查询参数，替换之前的设置，支持 * 号通配，如 utm_*，不区分大小写
+func (c *Canonicalizer) SetStripParams(patterns ...string) *Canonicalizer {
+	c.stripParams = c.stripParams[:0]
+	for _, p := range patterns {
+		p = strings.Replace(regexp.QuoteMeta(p), `\*`, ".*", -1)
+		c.stripParams = append(c.stripParams, regexp.MustCompile("(?i)^"+p+"$"))
+	}
+	return c
+}
+
+// SetLowerHost 设置是否将协议和主机转为小写，并去掉默认端口，如 HTTP://Example.COM:80/ 转为 http://example.com/
+func (c *Canonicalizer) SetLowerHost(v bool) *Canonicalizer {
+	c.lowerHost = v
+	return c
+}
+
+// SetRemoveFragment 设置是否去掉锚点，如 /a#top 转为 /a
+func (c *Canonicalizer) SetRemoveFragment(v bool) *Canonicalizer {
+	c.removeFragment = v
+	return c
+}
+
+// SetResolvePath 设置是否处理路径中的 . 和 ..，如 /a/./b/../c 转为 /a/c，空路径转为 /
+func (c *Canonicalizer) SetResolvePath(v bool) *Canonicalizer {
+	c.resolvePath = v
+	return c
+}
+
+// SetTrailingSlash 设置是否去掉路径末尾的斜杠，如 /a/ 转为 /a，网站区分两者时不要设置
+func (c *Canonicalizer) SetTrailingSlash(v bool) *Canonicalizer {
+	c.trailingSlash = v
+	return c
+}
+
+// Canonical 返回规范化的URL，无法解析时返回原URL，规范化为 nil 时不处理
+func (c *Canonicalizer) Canonical(raw string) string {
+	if c == nil {
+		return raw
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Opaque != "" {
+		return raw
+	}
+	if c.lowerHost {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		if port := u.Port(); port == "80" && u.Scheme == "http" || port == "443" && u.Scheme == "https" {
+			u.Host = u.Hostname()
+			if strings.Contains(u.Host, ":") {
+				u.Host = "[" + u.Host + "]" // IPv6
+			}
+		}
+	}
+	if c.resolvePath && u.Host != "" {
+		if p := u.EscapedPath(); p == "" {
+			u.Path, u.RawPath = "/", ""
+		} else if strings.Contains(p, "/.") {
+			cleaned := path.Clean(p)
+			if strings.HasSuffix(p, "/") && cleaned != "/" {
+				cleaned += "/"
+			}
+			u.Path, _ = url.PathUnescape(cleaned)
+			u.RawPath = cleaned
+		}
+	}
+	if c.trailingSlash && len(u.Path) > 1 && strings.HasSuffix(u.Path, "/") {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	}
+	if c.removeFragment {
+		u.Fragment, u.RawFragment = "", ""
+	}
+	u.RawQuery = c.query(u.RawQuery)
+	u.ForceQuery = false
+	return u.String()
+}
+
+// query 规范化查询字符串，保持参数的原始编码，排序时同名参数保持原来的顺序
+func (c *Canonicalizer) query(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	params := make([]string, 0, strings.Count(raw, "&")+1)
+	names := make([]string, 0, cap(params))
+	for _, p := range strings.Split(raw, "&") {
+		if p == "" {
+			continue
+		}
+		name := p
+		if i := strings.Index(p, "="); i >= 0 {
+			name = p[:i]
+		}
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+		if c.strip(name) {
+			continue
+		}
+		params = append(params, p)
+		names = append(names, name)
+	}
+	if c.sortParams {
+		sort.Stable(queryParams{params, names})
+	}
+	return strings.Join(params, "&")
+}
+
+// queryParams 按名称排序的查询参数
+type queryParams struct {
+	params []string // 原始的参数
+	names  []string // 解码后的参数名称
+}
+
+func (q queryParams) Len() int           { return len(q.params) }
+func (q queryParams) Less(i, j int) bool { return q.names[i] < q.names[j] }
+func (q queryParams) Swap(i, j int) {
+	q.params[i], q.params[j] = q.params[j], q.params[i]
+	q.names[i], q.names[j] = q.names[j], q.names[i]
+}
+
+// strip 判断查询参数是否要去掉
+func (c *Canonicalizer) strip(name string) bool {
+	for _, re := range c.stripParams {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Key 返回URI用于判断重复的标识，规范化的URL，有请求参数时包含按名称排序的参数
+func (c *Canonicalizer) Key(u *URI) string {
+	key := c.Canonical(u.URL)
+	if len(u.Req.Params) == 0 {
+		return key
+	}
+	vals := make(url.Values)
+	for k, v := range u.Req.Params {
+		vals.Set(k, v)
+	}
+	return key + "#" + vals.Encode()
+}
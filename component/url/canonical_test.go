@@ -0,0 +1,63 @@
+package url
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCanonical(t *testing.T) {
+	Convey("测试默认的URL规范化", t, func() {
+		c := NewCanonicalizer()
+		cases := map[string]string{
+			"http://example.com/a?b=2&a=1":                "http://example.com/a?a=1&b=2",
+			"http://example.com/a?a=1&utm_source=x&b=2":   "http://example.com/a?a=1&b=2",
+			"http://example.com/a?UTM_Medium=x&gclid=y":   "http://example.com/a",
+			"http://example.com/a?a=1&a=0":                "http://example.com/a?a=1&a=0",
+			"http://example.com/a?b=1&a=1&a=0":            "http://example.com/a?a=1&a=0&b=1",
+			"http://example.com/a?q=%E4%B8%AD&p=a%20b":    "http://example.com/a?p=a%20b&q=%E4%B8%AD",
+			"HTTP://Example.COM:80/a":                     "http://example.com/a",
+			"https://Example.com:443/a":                   "https://example.com/a",
+			"http://example.com:8080/a":                   "http://example.com:8080/a",
+			"http://[::1]:80/a":                           "http://[::1]/a",
+			"http://example.com":                          "http://example.com/",
+			"http://example.com/a/./b/../c":               "http://example.com/a/c",
+			"http://example.com/a/b/../":                  "http://example.com/a/",
+			"http://example.com/a/#top":                   "http://example.com/a/",
+			"http://example.com/a?":                       "http://example.com/a",
+			"mailto:a@example.com":                        "mailto:a@example.com",
+			" http://example.com/a?spm=1.2&fbclid=3&x=1 ": "http://example.com/a?x=1",
+		}
+		for raw, want := range cases {
+			So(c.Canonical(raw), ShouldEqual, want)
+		}
+	})
+
+	Convey("测试URL规范化的设置", t, func() {
+		c := NewCanonicalizer().SetTrailingSlash(true)
+		So(c.Canonical("http://example.com/a/"), ShouldEqual, "http://example.com/a")
+		So(c.Canonical("http://example.com/"), ShouldEqual, "http://example.com/")
+
+		c = NewCanonicalizer().SetSortParams(false).SetStripParams("ref")
+		So(c.Canonical("http://example.com/a?b=2&ref=x&a=1&utm_source=y"), ShouldEqual, "http://example.com/a?b=2&a=1&utm_source=y")
+
+		c = NewCanonicalizer().SetLowerHost(false).SetRemoveFragment(false).SetResolvePath(false)
+		So(c.Canonical("http://Example.COM:80/a/../b#top"), ShouldEqual, "http://Example.COM:80/a/../b#top")
+
+		var n *Canonicalizer
+		So(n.Canonical("HTTP://Example.COM/a?b=1&a=2"), ShouldEqual, "HTTP://Example.COM/a?b=1&a=2")
+	})
+
+	Convey("测试URI的重复标识", t, func() {
+		c := NewCanonicalizer()
+		a := NewURI("http://example.com/search?utm_source=x")
+		a.SetParam("q", "go")
+		a.SetParam("page", "2")
+		b := NewURI("http://example.com/search")
+		b.SetParam("page", "2")
+		b.SetParam("q", "go")
+		So(c.Key(a), ShouldEqual, c.Key(b))
+		So(c.Key(a), ShouldEqual, "http://example.com/search#page=2&q=go")
+		So(c.Key(NewURI("http://example.com/search")), ShouldEqual, "http://example.com/search")
+	})
+}
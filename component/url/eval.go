@@ -0,0 +1,53 @@
+package url
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/safeie/spider/component/parser"
+)
+
+// Evaluate 对内容执行一条字段规则，返回全部匹配及其位置，用于调试字段规则
+func Evaluate(body []byte, matchType int, rule string) ([]parser.Match, error) {
+	if rule == "" {
+		return nil, fmt.Errorf("字段提取规则为空")
+	}
+	var p parser.Finder
+	var err error
+	switch matchType {
+	case MatchTypeSelector:
+		p, err = parser.NewHTMLDom(body)
+	case MatchTypeSubString:
+		p, err = parser.NewSubstring(body)
+	case MatchTypeRegexp:
+		p, err = parser.NewRegexp(body)
+	case MatchTypeJSONPath:
+		p, err = parser.NewJSONPath(body)
+	case MatchTypeXPath:
+		p, err = parser.NewXPath(body)
+	case MatchTypeReadability:
+		p, err = parser.NewReadability(body)
+	case MatchTypeJSData:
+		p, err = parser.NewJSData(body)
+	case MatchTypeFeed:
+		p, err = parser.ParseFeed(body)
+	case MatchTypeTable:
+		p, err = parser.NewTable(body)
+	case MatchTypeRE2:
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(rule); err != nil {
+			return nil, err
+		}
+		var r *parser.Regexp
+		if r, err = parser.NewRegexp(body); err != nil {
+			return nil, err
+		}
+		return r.FindSubmatch(re), nil
+	default:
+		err = fmt.Errorf("不支持的字段提取类型")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(rule), nil
+}
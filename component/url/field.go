@@ -1,6 +1,7 @@
 package url
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -11,15 +12,22 @@ import (
 )
 
 const (
-	MatchTypeSelector  = iota // html Selector匹配
-	MatchTypeSubString        // 字符串截取
-	MatchTypeRegexp           // 正则表达式
-	MatchTypeJSONPath         // json path匹配
+	MatchTypeSelector    = iota // html Selector匹配
+	MatchTypeSubString          // 字符串截取
+	MatchTypeRegexp             // 正则表达式
+	MatchTypeJSONPath           // json path匹配
+	MatchTypeXPath              // html xpath匹配，页面类型为 XML 时为 XML xpath
+	MatchTypeRE2                // 真正的正则表达式(RE2)，命名分组作为子字段
+	MatchTypeReadability        // 正文提取，规则为 content, text, title, byline, date, image, images
+	MatchTypeJSData             // 页面中嵌入的JS数据，规则为变量名或JSONP回调，值为JSON，子字段使用 JSONPath
+	MatchTypeFeed               // RSS/Atom 订阅，规则为 entries, title, link, description, updated
+	MatchTypeTable              // CSV/XLSX 表格，规则为列名或 *(整行)，可以用 工作表!列名 指定工作表
 )
 
 const (
-	SourceTypeContext = iota // 当前内容
-	SourceTypeAttach         // URL附件字段
+	SourceTypeContext    = iota // 当前内容
+	SourceTypeAttach            // URL附件字段
+	SourceTypeStructured        // 页面中的结构化数据 JSON-LD, microdata, OpenGraph，规则为 类型.路径
 )
 
 // FieldFilterFunc 字段过滤方法
@@ -27,20 +35,27 @@ type FieldFilterFunc func(f *Field)
 
 // Field 字段
 type Field struct {
-	Name        string              // 字段名
-	Alias       string              // 别名（中文）
-	Remote      *Remote             // 远程获取字段附属页面
-	value       interface{}         // 字段值
-	sourceType  int                 // 字段来源，默认 当前页面中，可选，附加字段，远程字段 page,attach,remote
-	matchType   int                 // 匹配类型
-	matchRule   string              // 匹配规则
-	expand      bool                // 展开单个复数字段，即：只有一个孩子字段且该字段为数组时，展开该字段为多条数据
-	fixURL      bool                // 是否修复URL，修复可能的相对路径
-	fixed       bool                // 是否已经修复过URL
-	repeat      bool                // 是否重复
-	repeatValue []map[string]*Field // 重复字段值
-	children    []*Field            // 孩子字段
-	filters     []FieldFilterFunc   // 过滤函数集，优先于全局规则过滤器执行
+	Name         string              // 字段名
+	Alias        string              // 别名（中文）
+	Remote       *Remote             // 远程获取字段附属页面
+	value        interface{}         // 字段值
+	sourceType   int                 // 字段来源，默认 当前页面中，可选，附加字段，远程字段 page,attach,remote
+	matchType    int                 // 匹配类型
+	matchRule    string              // 匹配规则
+	re           *regexp.Regexp      // 编译好的正则，仅 MatchTypeRE2 使用，设置规则时编译一次
+	reErr        error               // 正则编译错误
+	expand       bool                // 展开单个复数字段，即：只有一个孩子字段且该字段为数组时，展开该字段为多条数据
+	fixURL       bool                // 是否修复URL，修复可能的相对路径
+	fixed        bool                // 是否已经修复过URL
+	repeat       bool                // 是否重复
+	repeatValue  []map[string]*Field // 重复字段值
+	children     []*Field            // 孩子字段
+	filters      []FieldFilterFunc   // 过滤函数集，优先于全局规则过滤器执行
+	valueType    int                 // 值的类型，默认不转换
+	layout       string              // 时间格式，仅 FieldTypeTime 使用
+	required     bool                // 是否必填
+	defaultValue interface{}         // 默认值，值为空或转换失败时使用
+	errorPolicy  int                 // 出错时的策略，默认使用规则的策略
 
 }
 
@@ -63,13 +78,17 @@ func (f *Field) Copy() *Field {
 		n.Remote = &Remote{
 			url:         f.Remote.url,
 			pageType:    f.Remote.pageType,
+			engine:      f.Remote.engine,
 			fetchOption: f.Remote.fetchOption,
 			logger:      f.Remote.logger,
+			observer:    f.Remote.observer,
 		}
 	}
 	n.sourceType = f.sourceType
 	n.matchType = f.matchType
 	n.matchRule = f.matchRule
+	n.re = f.re
+	n.reErr = f.reErr
 	n.expand = f.expand
 	n.repeat = f.repeat
 	n.fixURL = f.fixURL
@@ -77,6 +96,11 @@ func (f *Field) Copy() *Field {
 		n.children = append(n.children, f.children[i].Copy())
 	}
 	n.filters = f.filters
+	n.valueType = f.valueType
+	n.layout = f.layout
+	n.required = f.required
+	n.defaultValue = f.defaultValue
+	n.errorPolicy = f.errorPolicy
 	return n
 }
 
@@ -202,9 +226,14 @@ func (f *Field) SetValue(v interface{}) *Field {
 }
 
 // SetMatchRule 设置匹配规则
+// MatchTypeRE2 的规则在这里编译，字段复制时共享，编译错误在获取字段值时返回
 func (f *Field) SetMatchRule(matchType int, matchRule string) *Field {
 	f.matchType = matchType
 	f.matchRule = matchRule
+	f.re, f.reErr = nil, nil
+	if matchType == MatchTypeRE2 && matchRule != "" {
+		f.re, f.reErr = regexp.Compile(matchRule)
+	}
 	return f
 }
 
@@ -263,15 +292,29 @@ func (f *Field) Fetch(u *URI) error {
 		return fmt.Errorf("字段提取规则为空")
 	}
 	var err error
-	switch f.matchType {
-	case MatchTypeSelector:
+	switch {
+	case f.sourceType == SourceTypeStructured:
+		err = f.fetchStructured(u)
+	case f.matchType == MatchTypeSelector:
 		err = f.fetchSelector(u)
-	case MatchTypeSubString:
+	case f.matchType == MatchTypeSubString:
 		err = f.fetchSubstring(u)
-	case MatchTypeRegexp:
+	case f.matchType == MatchTypeRegexp:
 		err = f.fetchRegexp(u)
-	case MatchTypeJSONPath:
+	case f.matchType == MatchTypeJSONPath:
 		err = f.fetchJSON(u)
+	case f.matchType == MatchTypeXPath:
+		err = f.fetchXPath(u)
+	case f.matchType == MatchTypeRE2:
+		err = f.fetchRE2(u)
+	case f.matchType == MatchTypeReadability:
+		err = f.fetchReadability(u)
+	case f.matchType == MatchTypeJSData:
+		err = f.fetchJSData(u)
+	case f.matchType == MatchTypeFeed:
+		err = f.fetchFeed(u)
+	case f.matchType == MatchTypeTable:
+		err = f.fetchTable(u)
 	default:
 		err = fmt.Errorf("不支持的字段提取类型")
 	}
@@ -323,7 +366,7 @@ func (f *Field) fetchJSON(u *URI) error {
 				for j := range f.children {
 					cf := f.children[j].Copy()
 					if err = cf.Fetch(furi); err != nil {
-						return err
+						return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
 					}
 					repeatValue[cf.Name] = cf
 				}
@@ -339,7 +382,7 @@ func (f *Field) fetchJSON(u *URI) error {
 			}
 			for _, cf := range f.children {
 				if err = cf.Fetch(furi); err != nil {
-					return err
+					return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
 				}
 			}
 		}
@@ -348,6 +391,106 @@ func (f *Field) fetchJSON(u *URI) error {
 	return nil
 }
 
+// fetchStructured 获取页面中的结构化数据，子字段使用 JSONPath 从值的 JSON 中获取
+func (f *Field) fetchStructured(u *URI) error {
+	var err error
+	if u.Parser.Structured == nil {
+		if u.Parser.Structured, err = parser.NewStructuredData(u.Body); err != nil {
+			return err
+		}
+	}
+
+	return f.fetchMatch(u, u.Parser.Structured.Match, func(rule string) interface{} {
+		return u.Parser.Structured.MatchAll(rule)
+	})
+}
+
+// fetchMatch 按规则匹配字段的值，重复字段使用 matchAll，否则使用 match，
+// 有子字段时从每个值的内容或以值为参数的远程页面中获取子字段
+func (f *Field) fetchMatch(u *URI, match, matchAll func(rule string) interface{}) error {
+	if f.repeat {
+		f.value = matchAll(f.matchRule)
+	} else {
+		f.value = match(f.matchRule)
+	}
+	if f.children == nil {
+		return nil
+	}
+	if !f.repeat {
+		return f.fetchChildren(u, f.children, f.value)
+	}
+
+	var values []interface{}
+	switch v := f.value.(type) {
+	case []string:
+		for i := range v {
+			values = append(values, v[i])
+		}
+	case []interface{}:
+		values = v
+	default:
+		return nil
+	}
+	for i := range values {
+		children := make([]*Field, len(f.children))
+		for j := range f.children {
+			children[j] = f.children[j].Copy()
+		}
+		if err := f.fetchChildren(u, children, values[i]); err != nil {
+			return err
+		}
+		repeatValue := make(map[string]*Field)
+		for _, cf := range children {
+			repeatValue[cf.Name] = cf
+		}
+		f.repeatValue = append(f.repeatValue, repeatValue)
+	}
+	return nil
+}
+
+// fetchChildren 从值的内容或以值为参数的远程页面中获取子字段
+func (f *Field) fetchChildren(u *URI, children []*Field, v interface{}) error {
+	var err error
+	arg, body := matchContent(v)
+	furi := u.Copy()
+	if f.Remote != nil {
+		if furi, err = f.Remote.FetchURI(arg); err != nil {
+			return err
+		}
+	} else {
+		furi.ResetBody(body)
+	}
+	for _, cf := range children {
+		if err = cf.Fetch(furi); err != nil {
+			return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
+		}
+	}
+	return nil
+}
+
+// matchContent 返回值作为远程字段参数和子字段内容的形式，字符串直接使用，其他编码为JSON
+func matchContent(v interface{}) (string, []byte) {
+	if s, ok := v.(string); ok {
+		return s, []byte(s)
+	}
+	b, _ := json.Marshal(v)
+	return string(b), b
+}
+
+// matchString 将返回字符串的匹配方法转换为 fetchMatch 的匹配方法
+func matchString(fn func(string) string) func(string) interface{} {
+	return func(rule string) interface{} {
+		return fn(rule)
+	}
+}
+
+// matchStrings 将返回字符串列表的匹配方法转换为 fetchMatch 的匹配方法
+func matchStrings(fn func(string) []string) func(string) interface{} {
+	return func(rule string) interface{} {
+		return fn(rule)
+	}
+}
+
 // fetchSelector 解析html dom selector
 func (f *Field) fetchSelector(u *URI) error {
 	var err error
@@ -382,7 +525,7 @@ func (f *Field) fetchSelector(u *URI) error {
 				for j := range f.children {
 					cf := f.children[j].Copy()
 					if err = cf.Fetch(furi); err != nil {
-						return err
+						return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
 					}
 					repeatValue[cf.Name] = cf
 				}
@@ -398,7 +541,7 @@ func (f *Field) fetchSelector(u *URI) error {
 			}
 			for _, cf := range f.children {
 				if err = cf.Fetch(furi); err != nil {
-					return err
+					return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
 				}
 			}
 		}
@@ -407,6 +550,57 @@ func (f *Field) fetchSelector(u *URI) error {
 	return nil
 }
 
+// fetchXPath 解析html xpath
+func (f *Field) fetchXPath(u *URI) error {
+	if u.PageType == PageTypeXML {
+		return f.fetchXML(u)
+	}
+	var err error
+	if u.Parser.XPath == nil {
+		if u.Parser.XPath, err = parser.NewXPath(u.Body); err != nil {
+			return err
+		}
+	}
+
+	return f.fetchMatch(u, matchString(u.Parser.XPath.Match), matchStrings(u.Parser.XPath.MatchAll))
+}
+
+// fetchXML 解析XML xpath，页面类型为 XML 时使用
+func (f *Field) fetchXML(u *URI) error {
+	var err error
+	if u.Parser.XML == nil {
+		if u.Parser.XML, err = parser.NewXML(u.Body); err != nil {
+			return err
+		}
+	}
+
+	return f.fetchMatch(u, matchString(u.Parser.XML.Match), matchStrings(u.Parser.XML.MatchAll))
+}
+
+// fetchReadability 正文提取，子字段从提取的内容中获取
+func (f *Field) fetchReadability(u *URI) error {
+	var err error
+	if u.Parser.Readability == nil {
+		if u.Parser.Readability, err = parser.NewReadability(u.Body); err != nil {
+			return err
+		}
+	}
+
+	return f.fetchMatch(u, matchString(u.Parser.Readability.Match), matchStrings(u.Parser.Readability.MatchAll))
+}
+
+// fetchJSData 解析页面中嵌入的JS数据，子字段从转换后的JSON中获取
+func (f *Field) fetchJSData(u *URI) error {
+	var err error
+	if u.Parser.JSData == nil {
+		if u.Parser.JSData, err = parser.NewJSData(u.Body); err != nil {
+			return err
+		}
+	}
+
+	return f.fetchMatch(u, matchString(u.Parser.JSData.Match), matchStrings(u.Parser.JSData.MatchAll))
+}
+
 // fetchRegexp 正则解析
 func (f *Field) fetchRegexp(u *URI) error {
 	var err error
@@ -441,7 +635,7 @@ func (f *Field) fetchRegexp(u *URI) error {
 				for j := range f.children {
 					cf := f.children[j].Copy()
 					if err = cf.Fetch(furi); err != nil {
-						return err
+						return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
 					}
 					repeatValue[cf.Name] = cf
 				}
@@ -457,7 +651,7 @@ func (f *Field) fetchRegexp(u *URI) error {
 			}
 			for _, cf := range f.children {
 				if err = cf.Fetch(furi); err != nil {
-					return err
+					return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
 				}
 			}
 		}
@@ -466,6 +660,261 @@ func (f *Field) fetchRegexp(u *URI) error {
 	return nil
 }
 
+// fetchRE2 真正的正则匹配
+// 字段的值为第一个分组，没有分组时为整个匹配，每个命名分组作为同名的子字段，
+// 如果设置了同名的子字段，使用该子字段(及其过滤器)，其他子字段从整个匹配的内容中获取
+func (f *Field) fetchRE2(u *URI) error {
+	if f.reErr != nil {
+		return f.reErr
+	}
+	var err error
+	if u.Parser.Regexp == nil {
+		if u.Parser.Regexp, err = parser.NewRegexp(u.Body); err != nil {
+			return err
+		}
+	}
+
+	grouped := f.children != nil || f.hasNamedGroup()
+	if f.repeat {
+		matches := u.Parser.Regexp.SubmatchAll(f.re)
+		values := make([]string, len(matches))
+		for i, m := range matches {
+			values[i] = submatchValue(m)
+			if !grouped {
+				continue
+			}
+			children, err := f.groupFields(u, m)
+			if err != nil {
+				return err
+			}
+			repeatValue := make(map[string]*Field)
+			for _, cf := range children {
+				repeatValue[cf.Name] = cf
+			}
+			f.repeatValue = append(f.repeatValue, repeatValue)
+		}
+		f.value = values
+		if grouped && f.children == nil {
+			f.children = make([]*Field, 0)
+		}
+		return nil
+	}
+
+	m := u.Parser.Regexp.Submatch(f.re)
+	if m == nil {
+		f.value = ""
+		return nil
+	}
+	f.value = submatchValue(m)
+	if grouped {
+		if f.children, err = f.groupFields(u, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasNamedGroup 正则中是否有命名分组
+func (f *Field) hasNamedGroup() bool {
+	for _, name := range f.re.SubexpNames() {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// groupFields 根据一个正则匹配创建子字段，命名分组为子字段的值
+func (f *Field) groupFields(u *URI, m []string) ([]*Field, error) {
+	var names []string
+	var values []interface{}
+	for i, name := range f.re.SubexpNames() {
+		if name == "" || i >= len(m) {
+			continue
+		}
+		names = append(names, name)
+		values = append(values, m[i])
+	}
+	return f.namedFields(u, names, values, []byte(m[0]), submatchValue(m))
+}
+
+// namedFields 根据名称和值创建子字段，设置了同名的子字段时使用该子字段(及其过滤器)，
+// 其他子字段从 body 或以 remoteArg 获取的远程页面中获取
+func (f *Field) namedFields(u *URI, names []string, values []interface{}, body []byte, remoteArg string) ([]*Field, error) {
+	declared := make(map[string]*Field)
+	for _, c := range f.children {
+		declared[c.Name] = c
+	}
+
+	var fields []*Field
+	for i, name := range names {
+		var cf *Field
+		if c, ok := declared[name]; ok {
+			cf = c.Copy()
+			delete(declared, name)
+		} else {
+			cf = &Field{Name: name, Alias: name}
+		}
+		cf.value = values[i]
+		for _, fn := range cf.filters {
+			fn(cf)
+		}
+		fields = append(fields, cf)
+	}
+
+	// 其他子字段，从内容或远程页面中获取
+	if len(declared) > 0 {
+		var err error
+		furi := u.Copy()
+		if f.Remote != nil {
+			if furi, err = f.Remote.FetchURI(remoteArg); err != nil {
+				return nil, err
+			}
+		} else {
+			furi.ResetBody(body)
+		}
+		for _, c := range f.children {
+			if _, ok := declared[c.Name]; !ok {
+				continue
+			}
+			cf := c.Copy()
+			if err = cf.Fetch(furi); err != nil {
+				return nil, fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
+			}
+			fields = append(fields, cf)
+		}
+	}
+	return fields, nil
+}
+
+// submatchValue 返回正则匹配的值，有分组时为第一个分组，否则为整个匹配
+func submatchValue(m []string) string {
+	if len(m) > 1 {
+		return m[1]
+	}
+	if len(m) == 1 {
+		return m[0]
+	}
+	return ""
+}
+
+// fetchFeed 解析 RSS/Atom 订阅
+// 规则为 entries 时，条目的 id, title, link, summary, content, author, published, updated, categories, enclosure
+// 作为子字段，设置了同名的子字段时使用该子字段(及其过滤器)，其他子字段从条目的XML中获取，
+// 重复字段返回全部条目，否则返回第一个条目；规则为 title, link, description, updated 时返回订阅的属性
+func (f *Field) fetchFeed(u *URI) error {
+	var err error
+	if u.Parser.XML == nil {
+		if u.Parser.XML, err = parser.NewXML(u.Body); err != nil {
+			return err
+		}
+	}
+	feed := u.Parser.XML.Feed()
+	if feed == nil {
+		return fmt.Errorf("不是RSS或Atom订阅")
+	}
+	if f.matchRule != "entries" {
+		f.value = feed.Match(f.matchRule)
+		return nil
+	}
+
+	entries := feed.Entries
+	if !f.repeat {
+		if len(entries) == 0 {
+			f.value = ""
+			return nil
+		}
+		entries = entries[:1]
+	}
+	links := make([]string, len(entries))
+	for i, e := range entries {
+		links[i] = e.Link
+		entry := e.Map()
+		values := make([]interface{}, len(parser.FeedEntryKeys))
+		for j, key := range parser.FeedEntryKeys {
+			values[j] = entry[key]
+		}
+		fields, err := f.namedFields(u, parser.FeedEntryKeys, values, []byte(e.XML), e.Link)
+		if err != nil {
+			return err
+		}
+		if !f.repeat {
+			f.value = e.Link
+			f.children = fields
+			return nil
+		}
+		repeatValue := make(map[string]*Field)
+		for _, cf := range fields {
+			repeatValue[cf.Name] = cf
+		}
+		f.repeatValue = append(f.repeatValue, repeatValue)
+	}
+	f.value = links
+	if f.children == nil {
+		f.children = make([]*Field, 0)
+	}
+	return nil
+}
+
+// fetchTable 解析 CSV/XLSX 表格
+// 规则为列名时返回该列的值，重复字段返回每一行的值，否则返回第一行的值，子字段从值中获取；
+// 规则为 * 时每一列作为子字段，设置了同名的子字段时使用该子字段(及其过滤器)，其他子字段从整行的JSON中获取，
+// 重复字段返回全部行，否则返回第一行，值为整行的JSON
+func (f *Field) fetchTable(u *URI) error {
+	var err error
+	if u.Parser.Table == nil {
+		if u.Parser.Table, err = parser.NewTable(u.Body); err != nil {
+			return err
+		}
+	}
+
+	name, column := parser.ParseTableRule(f.matchRule)
+	if column == "*" {
+		sheet := u.Parser.Table.Sheet(name)
+		if sheet == nil {
+			return fmt.Errorf("表格中没有工作表 %s", name)
+		}
+		rows := sheet.Rows
+		if !f.repeat {
+			if len(rows) == 0 {
+				f.value = ""
+				return nil
+			}
+			rows = rows[:1]
+		}
+		var values []string
+		for _, row := range rows {
+			rowValues := make([]interface{}, len(row))
+			for i := range row {
+				rowValues[i] = row[i]
+			}
+			b, _ := json.Marshal(sheet.Map(row))
+			fields, err := f.namedFields(u, sheet.Header, rowValues, b, string(b))
+			if err != nil {
+				return err
+			}
+			if !f.repeat {
+				f.value = string(b)
+				f.children = fields
+				return nil
+			}
+			repeatValue := make(map[string]*Field)
+			for _, cf := range fields {
+				repeatValue[cf.Name] = cf
+			}
+			f.repeatValue = append(f.repeatValue, repeatValue)
+			values = append(values, string(b))
+		}
+		f.value = values
+		if f.children == nil {
+			f.children = make([]*Field, 0)
+		}
+		return nil
+	}
+
+	return f.fetchMatch(u, matchString(u.Parser.Table.Match), matchStrings(u.Parser.Table.MatchAll))
+}
+
 // fetchSubstring 字符串匹配
 func (f *Field) fetchSubstring(u *URI) error {
 	var err error
@@ -500,7 +949,7 @@ func (f *Field) fetchSubstring(u *URI) error {
 				for j := range f.children {
 					cf := f.children[j].Copy()
 					if err = cf.Fetch(furi); err != nil {
-						return err
+						return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
 					}
 					repeatValue[cf.Name] = cf
 				}
@@ -516,7 +965,7 @@ func (f *Field) fetchSubstring(u *URI) error {
 			}
 			for _, cf := range f.children {
 				if err = cf.Fetch(furi); err != nil {
-					return err
+					return fmt.Errorf("子字段[%s]获取失败：%v", cf.Name, err)
 				}
 			}
 		}
@@ -0,0 +1,63 @@
+package url
+
+import "fmt"
+
+// 字段错误策略，按严重程度排列，一行数据有多个字段错误时，执行最严重的策略
+const (
+	ErrorPolicyInherit = iota // 字段未设置，使用规则的策略，规则也未设置时同 ErrorPolicyIgnore
+	ErrorPolicyIgnore         // 记录错误，保存数据
+	ErrorPolicyPartial        // 记录错误，保存数据并标记为部分数据
+	ErrorPolicyDrop           // 丢弃该行数据
+	ErrorPolicyRetry          // 重新抓取页面再次提取，超过重试次数后丢弃该行数据
+	ErrorPolicyFail           // 终止任务
+)
+
+// FieldError 字段错误，包括提取错误和校验错误
+type FieldError struct {
+	Field  string // 字段名，子字段为 父字段.子字段
+	Err    error  // 错误
+	Policy int    // 错误策略，已按规则的策略确定
+}
+
+// Error 实现 error 接口
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("字段[%s]：%v", e.Field, e.Err)
+}
+
+// SetErrorPolicy 设置字段出错(提取失败或校验失败)时的策略，默认使用规则的策略
+func (f *Field) SetErrorPolicy(v int) *Field {
+	f.errorPolicy = v
+	return f
+}
+
+// ErrorPolicy 返回字段的错误策略
+func (f *Field) ErrorPolicy() int {
+	return f.errorPolicy
+}
+
+// AddFieldError 记录一个字段错误
+func (u *URI) AddFieldError(e *FieldError) {
+	u.fieldErrors = append(u.fieldErrors, e)
+}
+
+// FieldErrors 返回提取数据时的全部字段错误
+func (u *URI) FieldErrors() []*FieldError {
+	return u.fieldErrors
+}
+
+// ErrorPolicy 返回全部字段错误中最严重的策略，没有错误时为 ErrorPolicyInherit
+func (u *URI) ErrorPolicy() int {
+	policy := ErrorPolicyInherit
+	for _, e := range u.fieldErrors {
+		if e.Policy > policy {
+			policy = e.Policy
+		}
+	}
+	return policy
+}
+
+// ResetFields 清除字段和字段错误，用于重新提取
+func (u *URI) ResetFields() {
+	u.fields = nil
+	u.fieldErrors = nil
+}
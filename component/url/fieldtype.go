@@ -0,0 +1,271 @@
+package url
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FieldTypeAny    = iota // 不转换，保持匹配到的值
+	FieldTypeString        // 字符串
+	FieldTypeInt           // 整数 int64，允许千分位逗号
+	FieldTypeFloat         // 浮点数 float64，允许千分位逗号
+	FieldTypeBool          // 布尔值，支持 true/false, 1/0, yes/no, on/off, 是/否
+	FieldTypeTime          // 时间 time.Time，按设置的格式解析，默认 RFC3339
+	FieldTypeURL           // 绝对URL，必须有协议和主机
+	FieldTypeList          // 列表，单个值转为只有一个元素的列表，空值转为空列表
+)
+
+// ValidationError 字段值校验错误
+type ValidationError struct {
+	Field  string // 字段名，子字段为 父字段.子字段
+	Err    error  // 错误
+	Policy int    // 出错字段的错误策略
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("字段[%s]校验失败：%v", e.Field, e.Err)
+}
+
+// ValidationErrors 一个字段(含子字段)的全部校验错误
+type ValidationErrors []*ValidationError
+
+// Error 实现 error 接口
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// SetType 设置字段值的类型，获取后按类型转换，layout 为 FieldTypeTime 的时间格式
+// 重复字段的值按元素转换
+func (f *Field) SetType(t int, layout string) *Field {
+	f.valueType = t
+	f.layout = layout
+	return f
+}
+
+// SetRequired 设置是否必填，值为空且没有默认值时校验失败
+func (f *Field) SetRequired(v bool) *Field {
+	f.required = v
+	return f
+}
+
+// SetDefault 设置默认值，值为空或类型转换失败时使用，默认值同样按类型转换
+func (f *Field) SetDefault(v interface{}) *Field {
+	f.defaultValue = v
+	return f
+}
+
+// Validate 按字段类型转换字段值，使用默认值，检查必填，含子字段，返回全部校验错误
+// 应在全部过滤器之后执行，转换后的值不再是字符串，字符串过滤器不再生效
+func (f *Field) Validate() error {
+	errs := f.validate(f.Name, ErrorPolicyInherit)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// childrenEmpty 判断子字段是否全部为空，含多级子字段
+func (f *Field) childrenEmpty() bool {
+	for _, cf := range f.children {
+		switch {
+		case len(cf.children) == 0:
+			if !isEmptyValue(cf.value) {
+				return false
+			}
+		case cf.repeat:
+			if len(cf.repeatValue) > 0 {
+				return false
+			}
+		default:
+			if !cf.childrenEmpty() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validate 校验字段及其子字段，name 为包含父字段的名称，policy 为父字段的错误策略，子字段未设置策略时使用
+func (f *Field) validate(name string, policy int) ValidationErrors {
+	var errs ValidationErrors
+	if f.errorPolicy != ErrorPolicyInherit {
+		policy = f.errorPolicy
+	}
+	if f.children != nil {
+		if f.repeat {
+			for _, repeat := range f.repeatValue {
+				for _, cf := range repeat {
+					errs = append(errs, cf.validate(name+"."+cf.Name, policy)...)
+				}
+			}
+			if f.required && len(f.repeatValue) == 0 {
+				errs = append(errs, &ValidationError{name, fmt.Errorf("值为空"), policy})
+			}
+		} else {
+			for _, cf := range f.children {
+				errs = append(errs, cf.validate(name+"."+cf.Name, policy)...)
+			}
+			if f.required && f.childrenEmpty() {
+				errs = append(errs, &ValidationError{name, fmt.Errorf("值为空"), policy})
+			}
+		}
+		return errs
+	}
+
+	if isEmptyValue(f.value) && f.defaultValue != nil {
+		f.value = f.defaultValue
+	}
+	if isEmptyValue(f.value) {
+		if f.valueType == FieldTypeList {
+			f.value = []interface{}{}
+		} else if f.valueType != FieldTypeAny {
+			f.value = nil
+		}
+		if f.required {
+			errs = append(errs, &ValidationError{name, fmt.Errorf("值为空"), policy})
+		}
+		return errs
+	}
+
+	v, err := f.convert(f.value)
+	if err != nil && f.defaultValue != nil {
+		v, _ = f.convert(f.defaultValue)
+	}
+	f.value = v
+	if err != nil {
+		errs = append(errs, &ValidationError{name, err, policy})
+	}
+	return errs
+}
+
+// convert 按字段类型转换值，列表和重复字段的值按元素转换，转换失败的元素为 nil
+func (f *Field) convert(v interface{}) (interface{}, error) {
+	if f.valueType == FieldTypeAny {
+		return v, nil
+	}
+	var items []interface{}
+	switch value := v.(type) {
+	case []string:
+		for _, s := range value {
+			items = append(items, s)
+		}
+	case []interface{}:
+		items = value
+	default:
+		if f.valueType == FieldTypeList {
+			return []interface{}{v}, nil
+		}
+		return convertValue(v, f.valueType, f.layout)
+	}
+	if f.valueType == FieldTypeList {
+		return items, nil
+	}
+	var firstErr error
+	values := make([]interface{}, len(items))
+	for i := range items {
+		var err error
+		if values[i], err = convertValue(items[i], f.valueType, f.layout); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("第%d个值%v", i+1, err)
+		}
+	}
+	return values, firstErr
+}
+
+// convertValue 将单个值转换为指定类型
+func convertValue(v interface{}, t int, layout string) (interface{}, error) {
+	s, isString := v.(string)
+	if !isString {
+		switch value := v.(type) {
+		case []byte:
+			s, isString = string(value), true
+		case float64:
+			s = strconv.FormatFloat(value, 'f', -1, 64)
+		case int64:
+			s = strconv.FormatInt(value, 10)
+		case bool:
+			s = strconv.FormatBool(value)
+		case map[string]interface{}, []map[string]interface{}:
+			b, _ := json.Marshal(value)
+			s = string(b)
+		default:
+			s = fmt.Sprint(value)
+		}
+	}
+	s = strings.TrimSpace(s)
+
+	switch t {
+	case FieldTypeString:
+		return s, nil
+	case FieldTypeInt:
+		if n, ok := v.(float64); ok && n == float64(int64(n)) {
+			return int64(n), nil
+		}
+		n, err := strconv.ParseInt(strings.Replace(s, ",", "", -1), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是整数", s)
+		}
+		return n, nil
+	case FieldTypeFloat:
+		n, err := strconv.ParseFloat(strings.Replace(s, ",", "", -1), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是数字", s)
+		}
+		return n, nil
+	case FieldTypeBool:
+		switch strings.ToLower(s) {
+		case "true", "1", "yes", "y", "on", "是":
+			return true, nil
+		case "false", "0", "no", "n", "off", "否":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q 不是布尔值", s)
+	case FieldTypeTime:
+		if tm, ok := v.(time.Time); ok {
+			return tm, nil
+		}
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		tm, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%q 不符合时间格式 %s", s, layout)
+		}
+		return tm, nil
+	case FieldTypeURL:
+		pu, err := url.Parse(s)
+		if err != nil || pu.Scheme == "" || pu.Host == "" {
+			return nil, fmt.Errorf("%q 不是绝对URL", s)
+		}
+		return s, nil
+	}
+	return v, nil
+}
+
+// isEmptyValue 值是否为空：nil, 空字符串, 空列表
+func isEmptyValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []byte:
+		return len(value) == 0
+	case []string:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	case []map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
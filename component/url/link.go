@@ -0,0 +1,202 @@
+package url
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/safeie/spider/component/parser"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	// LinkDomainAny 不限制链接的域名
+	LinkDomainAny = iota
+	// LinkDomainSame 只保留与当前页面相同主机的链接
+	LinkDomainSame
+	// LinkDomainSubdomain 保留与当前页面同一主域名的链接，包括子域名，如 www.example.com 和 news.example.com
+	LinkDomainSubdomain
+)
+
+// LinkScope 链接的提取范围，用于只获取页面中需要的链接，而不是全部链接
+type LinkScope struct {
+	selectors []string         // 链接所在的区域，CSS选择器，默认整个页面
+	include   []*regexp.Regexp // 链接需要符合其中一个正则，为空不限制
+	exclude   []*regexp.Regexp // 符合其中一个正则的链接被丢弃
+	domain    int              // 域名限制
+	nofollow  bool             // 是否跳过 nofollow 的链接
+}
+
+// NewLinkScope 创建一个链接范围，默认整个页面的全部链接
+func NewLinkScope() *LinkScope {
+	return new(LinkScope)
+}
+
+// SetSelector 设置链接所在的区域，CSS选择器，如 div.list, ul.pager，区域中的 a 和 area 链接被获取
+func (s *LinkScope) SetSelector(v ...string) *LinkScope {
+	s.selectors = append(s.selectors, v...)
+	return s
+}
+
+// SetInclude 设置链接需要符合的正则，符合其中一个即可
+func (s *LinkScope) SetInclude(patterns ...string) *LinkScope {
+	for _, p := range patterns {
+		s.include = append(s.include, regexp.MustCompile(p))
+	}
+	return s
+}
+
+// SetExclude 设置需要丢弃的链接的正则，符合其中一个即丢弃
+func (s *LinkScope) SetExclude(patterns ...string) *LinkScope {
+	for _, p := range patterns {
+		s.exclude = append(s.exclude, regexp.MustCompile(p))
+	}
+	return s
+}
+
+// SetDomain 设置链接的域名限制，见 LinkDomainAny, LinkDomainSame, LinkDomainSubdomain
+func (s *LinkScope) SetDomain(v int) *LinkScope {
+	s.domain = v
+	return s
+}
+
+// SetNofollow 设置是否跳过 nofollow 的链接，即 rel 含有 nofollow 的链接，
+// 页面的 meta robots 含有 nofollow 时跳过全部链接
+func (s *LinkScope) SetNofollow(v bool) *LinkScope {
+	s.nofollow = v
+	return s
+}
+
+// Allow 判断链接是否在范围内，base 为链接所在页面的URL
+func (s *LinkScope) Allow(base, href string) bool {
+	if s.domain != LinkDomainAny {
+		pb, err := url.Parse(base)
+		if err != nil {
+			return false
+		}
+		ph, err := url.Parse(href)
+		if err != nil {
+			return false
+		}
+		if !sameDomain(pb.Hostname(), ph.Hostname(), s.domain == LinkDomainSubdomain) {
+			return false
+		}
+	}
+	if len(s.include) > 0 {
+		matched := false
+		for _, re := range s.include {
+			if re.MatchString(href) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range s.exclude {
+		if re.MatchString(href) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameDomain 判断两个主机是否相同，subdomain 为 true 时比较主域名
+func sameDomain(a, b string, subdomain bool) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return true
+	}
+	if !subdomain {
+		return false
+	}
+	da, err := publicsuffix.EffectiveTLDPlusOne(a)
+	if err != nil {
+		return false
+	}
+	db, err := publicsuffix.EffectiveTLDPlusOne(b)
+	if err != nil {
+		return false
+	}
+	return da == db
+}
+
+// FetchLinks 按范围获取内容中的链接，返回的URI复制当前页面的附加数据，Referer 为当前页面
+// 范围为 nil 时同 FetchURLs，XML 页面忽略区域
+func (u *URI) FetchLinks(s *LinkScope) []*URI {
+	if s == nil {
+		s = NewLinkScope()
+	}
+	var hrefs []string
+	switch {
+	case len(u.Body) == 0:
+		return nil
+	case u.PageType == PageTypeHTML && (len(s.selectors) > 0 || s.nofollow):
+		hrefs = u.fetchScopeURLs(s)
+	default:
+		hrefs = u.FetchURLs()
+	}
+
+	var uris []*URI
+	seen := make(map[string]bool)
+	for _, href := range hrefs {
+		if seen[href] || !s.Allow(u.URL, href) {
+			continue
+		}
+		seen[href] = true
+		uris = append(uris, u.Follow(href))
+	}
+	return uris
+}
+
+// fetchScopeURLs 获取区域中的链接，处理 nofollow
+func (u *URI) fetchScopeURLs(s *LinkScope) []string {
+	if u.Parser.HTMLDom == nil {
+		var err error
+		u.Parser.HTMLDom, err = parser.NewHTMLDom(u.Body)
+		if err != nil {
+			return nil
+		}
+	}
+	dom := u.Parser.HTMLDom
+	if s.nofollow {
+		for _, content := range dom.MatchAll("meta[name=robots]::attr(content)") {
+			if hasToken(content, "nofollow") || hasToken(content, "none") {
+				return nil
+			}
+		}
+	}
+	selectors := s.selectors
+	if len(selectors) == 0 {
+		selectors = []string{"html"}
+	}
+	var urls []string
+	for _, selector := range selectors {
+		sel := dom.DomFind(selector)
+		if sel == nil {
+			continue
+		}
+		for _, node := range sel.Find("a[href], area[href]").Nodes {
+			if s.nofollow && hasToken(dom.NodeAttr(node, "rel"), "nofollow") {
+				continue
+			}
+			if href := u.FixURL(strings.TrimSpace(dom.NodeAttr(node, "href"))); href != "" {
+				urls = append(urls, href)
+			}
+		}
+	}
+	return urls
+}
+
+// hasToken 判断以空白或逗号分隔的属性值中是否有某个值，不区分大小写
+func hasToken(v, token string) bool {
+	for _, s := range strings.FieldsFunc(strings.ToLower(v), func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t' || r == '\n'
+	}) {
+		if s == token {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/safeie/spider/common/log"
 	"github.com/safeie/spider/component/fetcher"
@@ -18,12 +19,23 @@ type Remote struct {
 	engine      int              // 抓取引擎
 	fetchOption *fetcher.Option  // 获取参数
 	logger      log.SimpleLogger // 日志器
+	observer    FetchObserver    // 抓取的观察者，用于统计
 }
 
-// NewRemote 创建一个新的远程获取
+// FetchObserver 抓取的观察者，每次抓取后调用，用于统计
+//
+// res 在抓取失败时可能为 nil
+type FetchObserver interface {
+	ObserveFetch(source, rawurl string, engine int, res *fetcher.Response, retries int, d time.Duration, err error)
+}
+
+// NewRemote 创建一个新的远程获取，logger 实现了 FetchObserver 时（如任务）将统计远程页面的抓取
 func NewRemote(logger log.SimpleLogger, pageType int, url string) *Remote {
 	t := new(Remote)
 	t.logger = logger
+	if o, ok := logger.(FetchObserver); ok {
+		t.observer = o
+	}
 	t.pageType = pageType
 	t.url = url
 	t.fetchOption = fetcher.NewOption("")
@@ -51,7 +63,11 @@ func (t *Remote) FetchURI(v string) (*URI, error) {
 			}
 		}
 	}
+	start := time.Now()
 	res, err := fetch.Fetch(u.URL, u.Req.Params, u.Req.Header)
+	if t.observer != nil {
+		t.observer.ObserveFetch("remote", u.URL, t.engine, res, 0, time.Since(start), err)
+	}
 	if err != nil {
 		t.logger.Printf("字段远程页面抓取失败 %s: %v", u.URL, err)
 		return nil, fmt.Errorf("Field.Remote.Fetch error: %v", err)
@@ -61,6 +77,10 @@ func (t *Remote) FetchURI(v string) (*URI, error) {
 	u.Code = res.Code
 	u.Body = res.Body
 	u.Header = res.Header
+	if err = u.DecodeBody(); err != nil {
+		t.logger.Printf("字段远程页面内容转换失败 %s: %v", u.URL, err)
+		return nil, fmt.Errorf("Field.Remote.DecodeBody error: %v", err)
+	}
 
 	return u, nil
 }
@@ -76,6 +96,12 @@ func (t *Remote) EnableJS(ok bool) *Remote {
 	return t
 }
 
+// SetObserver 设置抓取的观察者，nil 不统计
+func (t *Remote) SetObserver(o FetchObserver) *Remote {
+	t.observer = o
+	return t
+}
+
 // SetMethod 设置HTTP请求方法
 func (t *Remote) SetMethod(v string) *Remote {
 	t.fetchOption.SetMethod(v)
@@ -0,0 +1,113 @@
+package url
+
+import (
+	"html"
+	"time"
+
+	"github.com/safeie/spider/common/util"
+)
+
+// 转换类的过滤器，输入和输出都是字符串，可以组合使用，
+// 需要类型化的值时，配合字段类型(SetType)使用，如 FilterPrice 之后转换为 FieldTypeFloat
+
+// FilterHTMLUnescape 过滤器，解码HTML实体，如 &lt; &nbsp; &#39;
+func FilterHTMLUnescape(f *Field) {
+	v := f.String()
+	if v == "" {
+		return
+	}
+	f.SetValue(html.UnescapeString(v))
+}
+
+// FilterNormalizeSpace 过滤器，合并连续的空白为一个空格，并去掉两端的空白
+func FilterNormalizeSpace(f *Field) {
+	v := f.String()
+	if v == "" {
+		return
+	}
+	f.SetValue(util.NormalizeSpace(v))
+}
+
+// FilterMarkdown 过滤器，将HTML转换为 Markdown
+func FilterMarkdown(f *Field) {
+	v := f.String()
+	if v == "" {
+		return
+	}
+	f.SetValue(util.HTMLToMarkdown(v))
+}
+
+// FilterChineseNumber 过滤器，将中文数字替换为阿拉伯数字，如 第十二章 替换为 第12章，一万二千 替换为 12000
+func FilterChineseNumber(f *Field) {
+	v := f.String()
+	if v == "" {
+		return
+	}
+	f.SetValue(util.ReplaceChineseNumbers(v))
+}
+
+// FilterCurrency 过滤器，将值替换为其中的货币代码(ISO 4217)，如 "€ 12,50" 替换为 EUR
+func FilterCurrency(f *Field) {
+	v := f.String()
+	if v == "" {
+		return
+	}
+	f.SetValue(util.Currency(v))
+}
+
+// FilterNumber 返回一个过滤器，提取值中的第一个数字，按语言处理千分位和小数点，
+// locale 为空时自动判断，如 "阅读 1.2万" 替换为 12000，"1.234,5"(de) 替换为 1234.5
+// 没有数字时保持原值，由字段类型校验报告错误
+func FilterNumber(locale string) FieldFilterFunc {
+	return func(f *Field) {
+		v := f.String()
+		if v == "" {
+			return
+		}
+		if n, err := util.ParseNumber(v, locale); err == nil {
+			f.SetValue(util.FormatNumber(n))
+		}
+	}
+}
+
+// FilterPrice 返回一个过滤器，提取值中的价格，去掉货币符号，如 "￥1,299.00" 替换为 1299
+// 货币代码使用 FilterCurrency 从原值中另外获取
+func FilterPrice(locale string) FieldFilterFunc {
+	return func(f *Field) {
+		v := f.String()
+		if v == "" {
+			return
+		}
+		if n, _, err := util.ParsePrice(v, locale); err == nil {
+			f.SetValue(util.FormatNumber(n))
+		}
+	}
+}
+
+// FilterDate 返回一个过滤器，解析相对时间和中文时间，如 "3小时前", "昨天 12:30", "2006年1月2日"，
+// 按 layout 格式化，layout 为空时使用 2006-01-02 15:04:05，无法解析时保持原值
+func FilterDate(layout string) FieldFilterFunc {
+	if layout == "" {
+		layout = "2006-01-02 15:04:05"
+	}
+	return func(f *Field) {
+		v := f.String()
+		if v == "" {
+			return
+		}
+		if t, err := util.ParseTime(v, time.Now()); err == nil {
+			f.SetValue(t.Format(layout))
+		}
+	}
+}
+
+// FilterQueryParam 返回一个过滤器，将URL替换为其中查询参数 name 的值，没有该参数时为空字符串
+func FilterQueryParam(name string) FieldFilterFunc {
+	return func(f *Field) {
+		v := f.String()
+		if v == "" {
+			return
+		}
+		f.SetValue(util.QueryParam(v, name))
+	}
+}
@@ -3,6 +3,7 @@ package url
 import (
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/safeie/spider/component/fetcher"
 	"github.com/safeie/spider/component/parser"
@@ -15,28 +16,46 @@ const (
 	PageTypeJSON
 	// PageTypeText 页面类型，纯文本
 	PageTypeText
+	// PageTypeXML 页面类型，XML文档，包括 RSS/Atom 订阅和网站地图
+	PageTypeXML
+	// PageTypeCSV 页面类型，CSV表格，每行数据按列名访问
+	PageTypeCSV
+	// PageTypeXLSX 页面类型，Excel表格(xlsx)，每个工作表的每行数据按列名访问
+	PageTypeXLSX
+	// PageTypePDF 页面类型，PDF文档，抓取后转为文本，页与页之间以换页符分隔
+	PageTypePDF
 )
 
+// InternalKeyPrefix 附加数据中任务内部使用的键的前缀，如分页的页数，Follow 时不复制
+const InternalKeyPrefix = "_"
+
 // URI 是URL的组成单元，不直接使用string的原因是可以附加数据
 type URI struct {
-	URL       string                 // URL
-	parsedURL *url.URL               // 标准的URL解析
-	PageType  int                    // 页面类型
-	Code      int                    // 请求的响应码
-	Header    http.Header            // 请求的响应头
-	Body      []byte                 // 请求的响应体
-	Fetched   bool                   // 是否抓取过
-	fields    []*Field               // 字段
-	attach    map[string]interface{} // 附加数据
-	Req       struct {               // 请求参数
+	URL         string                 // URL
+	parsedURL   *url.URL               // 标准的URL解析
+	PageType    int                    // 页面类型
+	Code        int                    // 请求的响应码
+	Header      http.Header            // 请求的响应头
+	Body        []byte                 // 请求的响应体
+	Fetched     bool                   // 是否抓取过
+	fields      []*Field               // 字段
+	fieldErrors []*FieldError          // 提取字段时的错误
+	attach      map[string]interface{} // 附加数据
+	Req         struct {               // 请求参数
 		Header map[string]string
 		Params map[string]string
 	}
 	Parser struct { // 解析器
-		JSON      *parser.JSONPath
-		Regexp    *parser.Regexp
-		Substring *parser.Substring
-		HTMLDom   *parser.HTMLDom
+		JSON        *parser.JSONPath
+		Regexp      *parser.Regexp
+		Substring   *parser.Substring
+		HTMLDom     *parser.HTMLDom
+		XPath       *parser.XPath
+		Structured  *parser.StructuredData
+		Readability *parser.Readability
+		JSData      *parser.JSData
+		XML         *parser.XML
+		Table       *parser.Table
 	}
 }
 
@@ -74,9 +93,42 @@ func (u *URI) ResetBody(body []byte) {
 	u.Parser.Regexp = nil
 	u.Parser.Substring = nil
 	u.Parser.HTMLDom = nil
+	u.Parser.XPath = nil
+	u.Parser.Structured = nil
+	u.Parser.Readability = nil
+	u.Parser.JSData = nil
+	u.Parser.XML = nil
+	u.Parser.Table = nil
+}
+
+// DecodeBody 按页面类型转换抓取到的内容，PDF 文档转为文本，其它类型不处理
+// 已经转换过的内容不再转换
+func (u *URI) DecodeBody() error {
+	if u.PageType != PageTypePDF || !parser.IsPDF(u.Body) {
+		return nil
+	}
+	body, err := parser.PDFText(u.Body)
+	if err != nil {
+		return err
+	}
+	u.ResetBody(body)
+	return nil
+}
+
+// Follow 创建当前页面产生的新URI，复制附加数据，以 _ 开头的内部数据不复制，请求头 Referer 为当前页面
+func (u *URI) Follow(href string) *URI {
+	n := NewURI(href)
+	for k, v := range u.attach {
+		if strings.HasPrefix(k, InternalKeyPrefix) {
+			continue
+		}
+		n.attach[k] = v
+	}
+	n.SetHeader("Referer", u.URL)
+	return n
 }
 
-// Set 设置一个附加属性
+// Set 设置一个附加属性，以 _ 开头的键为任务内部使用，不会复制到页面产生的新URI
 func (u *URI) Set(key string, val interface{}) {
 	u.attach[key] = val
 }
@@ -86,6 +138,11 @@ func (u *URI) Get(key string) interface{} {
 	return u.attach[key]
 }
 
+// Delete 删除一个附加属性
+func (u *URI) Delete(key string) {
+	delete(u.attach, key)
+}
+
 // Gets 返回附加的属性列表
 func (u *URI) Gets() map[string]interface{} {
 	return u.attach
@@ -101,13 +158,24 @@ func (u *URI) SetParam(key, val string) {
 	u.Req.Params[key] = val
 }
 
+// urlSources 页面中链接的来源，a, area, link(样式表和图标除外), iframe, frame
+var urlSources = []string{
+	"a::attr(href)",
+	"area::attr(href)",
+	"link:not([rel~=stylesheet]):not([rel~=icon])::attr(href)",
+	"iframe::attr(src)",
+	"frame::attr(src)",
+}
+
 // FetchURLs 获取内容中的URL列表
 func (u *URI) FetchURLs() []string {
-	if u.PageType != PageTypeHTML {
+	if len(u.Body) == 0 {
 		return nil
 	}
-
-	if len(u.Body) == 0 {
+	if u.PageType == PageTypeXML {
+		return u.fetchXMLURLs()
+	}
+	if u.PageType != PageTypeHTML {
 		return nil
 	}
 
@@ -119,16 +187,43 @@ func (u *URI) FetchURLs() []string {
 		}
 	}
 	var urls []string
-	as := u.Parser.HTMLDom.DomFind("a")
-	for i := 0; i < as.Size(); i++ {
-		if href := u.Parser.HTMLDom.NodeAttr(as.Get(i), "href"); href != "" {
-			urls = append(urls, u.FixURL(href))
+	for _, rule := range urlSources {
+		for _, href := range u.Parser.HTMLDom.MatchAll(rule) {
+			if href = u.FixURL(strings.TrimSpace(href)); href != "" {
+				urls = append(urls, href)
+			}
 		}
 	}
 
 	return urls
 }
 
+// fetchXMLURLs 获取 XML 中的URL列表，订阅为条目的链接，网站地图为 loc
+func (u *URI) fetchXMLURLs() []string {
+	if u.Parser.XML == nil {
+		var err error
+		u.Parser.XML, err = parser.NewXML(u.Body)
+		if err != nil {
+			return nil
+		}
+	}
+	var hrefs []string
+	if feed := u.Parser.XML.Feed(); feed != nil {
+		for _, e := range feed.Entries {
+			hrefs = append(hrefs, e.Link)
+		}
+	} else {
+		hrefs = u.Parser.XML.MatchAll("//*[local-name()='url' or local-name()='sitemap']/*[local-name()='loc']")
+	}
+	var urls []string
+	for _, href := range hrefs {
+		if href = u.FixURL(strings.TrimSpace(href)); href != "" {
+			urls = append(urls, href)
+		}
+	}
+	return urls
+}
+
 // AddFields 添加字段
 func (u *URI) AddFields(ff ...*Field) {
 	u.fields = append(u.fields, ff...)
@@ -143,6 +238,16 @@ func (u *URI) ExportFields() map[string]interface{} {
 	return store
 }
 
+// FieldsEmpty 是否没有提取到数据，没有字段或全部字段的值为空
+func (u *URI) FieldsEmpty() bool {
+	for _, f := range u.fields {
+		if !isEmptyValue(f.Value()) {
+			return false
+		}
+	}
+	return true
+}
+
 // FixURL 过滤非本站URL和修复相对路径
 func (u *URI) FixURL(href string) string {
 	return fetcher.FixURL(u.URL, href)
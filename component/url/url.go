@@ -1,7 +1,7 @@
 package url
 
 import (
-	"sync"
+	"github.com/safeie/spider/component/dedup"
 )
 
 // URL URL组件
@@ -11,12 +11,12 @@ import (
  * ruleURL，处理过程中产生的 规则URL，这些URL要经过规则处理，不符合规则的被丢弃
  */
 type URL struct {
-	inited   bool            // 判断是否初试化的标志位
-	initfunc URLinitFunc     // 初始化函数
-	initURLs []string        // 入口URL，无条件抓取所有的url
-	ruleURLs chan *URI       // 待处理的URL列表，所有的URL将经过规则处理
-	crawled  map[string]bool // 存储抓取过的URL，用于判断是否还要抓取
-	rw       sync.Mutex
+	inited   bool           // 判断是否初试化的标志位
+	initfunc URLinitFunc    // 初始化函数
+	initURLs []string       // 入口URL，无条件抓取所有的url
+	ruleURLs chan *URI      // 待处理的URL列表，所有的URL将经过规则处理
+	crawled  dedup.Store    // 存储抓取过的URL，用于判断是否还要抓取，键为规范化的URL
+	canon    *Canonicalizer // URL规范化，用于判断重复
 }
 
 // URLinitFunc URL初试化函数
@@ -27,15 +27,16 @@ func NewURL() *URL {
 	t := new(URL)
 	t.initURLs = make([]string, 0)
 	t.ruleURLs = make(chan *URI, 100000)
-	t.crawled = make(map[string]bool)
+	t.crawled = dedup.NewMap()
+	t.canon = NewCanonicalizer()
 	return t
 }
 
-// Reset 复用
+// Reset 复用，清空抓取过的URL，保存到文件的存储不清空，下次运行仍跳过抓取过的URL
 func (t *URL) Reset() *URL {
-	t.rw.Lock()
-	t.crawled = make(map[string]bool)
-	t.rw.Unlock()
+	if !dedup.Persistent(t.crawled) {
+		t.crawled.Reset()
+	}
 	return t
 }
 
@@ -44,34 +45,62 @@ func (t *URL) SetInitFunc(f URLinitFunc) {
 	t.initfunc = f
 }
 
+// SetStore 设置判断重复的存储，默认为内存存储
+func (t *URL) SetStore(s dedup.Store) {
+	t.crawled = s
+}
+
+// Store 返回判断重复的存储
+func (t *URL) Store() dedup.Store {
+	return t.crawled
+}
+
+// SetCanonicalizer 设置URL规范化，用于判断重复，nil 时使用原始的URL判断
+func (t *URL) SetCanonicalizer(c *Canonicalizer) {
+	t.canon = c
+}
+
+// Canonical 返回规范化的URL
+func (t *URL) Canonical(url string) string {
+	return t.canon.Canonical(url)
+}
+
 // GetInitURLs 获取入口URL
 func (t *URL) GetInitURLs() []string {
 	return t.initURLs
 }
 
 // PushURL 插入一个ruleURL
-// 判断重复，防止进入无限循环，采集过就不再入队列
+// 判断重复，防止进入无限循环，采集过就不再入队列，规范化后相同的URL算重复
 func (t *URL) PushURL(url string) {
-	t.rw.Lock()
-	if t.crawled[url] {
-		t.rw.Unlock()
+	if t.crawled.Add(t.canon.Canonical(url)) {
 		return
 	}
-	t.crawled[url] = true
-	t.rw.Unlock()
 	t.ruleURLs <- NewURI(url)
 }
 
+// PushInitURL 插入一个入口URL，记录为抓取过，但不判断重复，存储保留到下次运行时入口URL仍会抓取
+func (t *URL) PushInitURL(url string) {
+	t.crawled.Add(t.canon.Canonical(url))
+	t.ruleURLs <- NewURI(url)
+}
+
+// PushDueURL 插入一个到期需要重新抓取的URL，不判断重复
+func (t *URL) PushDueURL(url string) {
+	t.ruleURLs <- NewURI(url)
+}
+
+// Requeue 重新插入一个URI，不判断重复，用于稍后重新抓取
+func (t *URL) Requeue(uri *URI) {
+	t.ruleURLs <- uri
+}
+
 // Push 插入一个ruleURL URI结构
-// 判断重复，防止进入无限循环，采集过就不再入队列
+// 判断重复，防止进入无限循环，采集过就不再入队列，规范化后相同的URL算重复，请求参数不同的不算重复
 func (t *URL) Push(uri *URI) {
-	t.rw.Lock()
-	if t.crawled[uri.URL] {
-		t.rw.Unlock()
+	if t.crawled.Add(t.canon.Key(uri)) {
 		return
 	}
-	t.crawled[uri.URL] = true
-	t.rw.Unlock()
 	t.ruleURLs <- uri
 }
 
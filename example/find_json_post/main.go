@@ -5,7 +5,6 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
@@ -23,20 +22,11 @@ func main() {
 	t.SetMethod(task.MethodPOST)
 	// set init urls
 	t.SetURLinitFunc(func() []string {
-		urls := make([]string, 0)
-		for i := 1; i <= 3; i++ {
-			urls = append(urls, fmt.Sprintf("https://internal.bevol.cn/good/article/findByList?pager=%d", i))
-		}
-		return urls
+		return []string{"https://internal.bevol.cn/good/article/findByList"}
 	})
 	t.SetParam("pageSize", "10")
 	t.SetParam("hidden", "0")
 	t.SetParam("pager", "1")
-	t.SetFetchFunc(nil, func(uri *url.URI) {
-		pos := strings.Index(uri.URL, "=")
-		pager := uri.URL[pos+1:]
-		uri.SetParam("pager", pager)
-	}, nil)
 
 	// i can the queue before quit
 	t.SetBeforeQuitFunc(func(taskID string, queue []string) {
@@ -64,6 +54,9 @@ func main() {
 			content,
 		)
 
+	// next pages post the same params with pager=2, 3
+	pages := task.NewPagination().SetPageParam("pager").SetMaxPages(3)
+
 	t.Rule("https://internal.bevol.cn/good/article/findByList*").
 		SetName("content rule"). // set rule name
 		Row(data).               // parse page fields
@@ -76,7 +69,8 @@ func main() {
 				fmt.Printf("%5d\tmid: %v\ttitle: %s\n", j+1, content["mid"], row["title"])
 			}
 			return nil
-		}, nil, nil).Save()
+		}, nil, nil).Save().
+		Paginate(pages) // then post the next page
 
 	// trap signal
 	close := make(chan struct{})